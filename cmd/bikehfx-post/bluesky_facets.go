@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/bluesky-social/indigo/api/atproto"
+	"github.com/bluesky-social/indigo/api/bsky"
+	"github.com/bluesky-social/indigo/xrpc"
+	"github.com/graxinc/errutil"
+)
+
+// handleResolver resolves Bluesky handles, without the @, to DIDs.
+type handleResolver interface {
+	resolveHandle(ctx context.Context, handle string) (string, error)
+}
+
+func (b blueskyPoster) resolveHandle(ctx context.Context, handle string) (string, error) {
+	var resp *atproto.IdentityResolveHandle_Output
+	err := b.do(ctx, func(c *xrpc.Client) (err error) {
+		resp, err = atproto.IdentityResolveHandle(ctx, c, handle)
+		return err
+	})
+	if err != nil {
+		return "", errutil.Witht(err, errutil.Tags{"handle": handle})
+	}
+	return resp.Did, nil
+}
+
+var bskyHandleRegex = regexp.MustCompile(`^([a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?\.)+[a-zA-Z]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?$`)
+
+// bskyMaxTagGraphemes is the longest tag Bluesky accepts.
+const bskyMaxTagGraphemes = 64
+
+// buildFacets returns link, mention and tag facets for text, with UTF-8 byte
+// offsets as Bluesky expects. Each is found at the start of a word, or just
+// after an opening parenthesis, and trailing punctuation is left out.
+// Mentions of handles that do not resolve are left as plain text.
+func buildFacets(ctx context.Context, text string, r handleResolver) []*bsky.RichtextFacet {
+	var out []*bsky.RichtextFacet
+	add := func(start, end int, feature *bsky.RichtextFacet_Features_Elem) {
+		out = append(out, &bsky.RichtextFacet{
+			Index:    &bsky.RichtextFacet_ByteSlice{ByteStart: int64(start), ByteEnd: int64(end)},
+			Features: []*bsky.RichtextFacet_Features_Elem{feature},
+		})
+	}
+
+	for start, word := range facetWords(text) {
+		if w, ok := strings.CutPrefix(word, "("); ok {
+			start++
+			word = w
+		}
+
+		switch {
+		case strings.HasPrefix(word, "http://") || strings.HasPrefix(word, "https://"):
+			link := trimLink(word)
+			if u, err := url.Parse(link); err != nil || u.Host == "" {
+				continue
+			}
+			add(start, start+len(link), &bsky.RichtextFacet_Features_Elem{
+				RichtextFacet_Link: &bsky.RichtextFacet_Link{Uri: link},
+			})
+
+		case strings.HasPrefix(word, "@"):
+			handle := strings.TrimRight(leadingHandle(word[1:]), ".-")
+			if !bskyHandleRegex.MatchString(handle) {
+				continue
+			}
+			did, err := r.resolveHandle(ctx, handle)
+			if err != nil {
+				fmt.Println("could not resolve bluesky handle", handle, "leaving it unlinked:", err)
+				continue
+			}
+			add(start, start+1+len(handle), &bsky.RichtextFacet_Features_Elem{
+				RichtextFacet_Mention: &bsky.RichtextFacet_Mention{Did: did},
+			})
+
+		default:
+			tag, n, ok := parseTag(word)
+			if !ok {
+				continue
+			}
+			add(start, start+n, &bsky.RichtextFacet_Features_Elem{
+				RichtextFacet_Tag: &bsky.RichtextFacet_Tag{Tag: tag},
+			})
+		}
+	}
+
+	return out
+}
+
+// facetWords yields each run of non-space characters in text with its byte
+// offset.
+func facetWords(text string) func(yield func(int, string) bool) {
+	return func(yield func(int, string) bool) {
+		start := -1
+		for i, r := range text {
+			if unicode.IsSpace(r) {
+				if start >= 0 && !yield(start, text[start:i]) {
+					return
+				}
+				start = -1
+			} else if start < 0 {
+				start = i
+			}
+		}
+		if start >= 0 {
+			yield(start, text[start:])
+		}
+	}
+}
+
+// trimLink removes punctuation that likely ends the sentence rather than the
+// link, including a closing parenthesis with no opening one in the link.
+func trimLink(link string) string {
+	for {
+		trimmed := strings.TrimRight(link, `.,;:!?'"`)
+		if strings.HasSuffix(trimmed, ")") && strings.Count(trimmed, "(") < strings.Count(trimmed, ")") {
+			trimmed = trimmed[:len(trimmed)-1]
+		}
+		if trimmed == link {
+			return link
+		}
+		link = trimmed
+	}
+}
+
+func leadingHandle(s string) string {
+	end := strings.IndexFunc(s, func(r rune) bool {
+		return !(r < utf8.RuneSelf && (r == '.' || r == '-' || unicode.IsLetter(r) || unicode.IsDigit(r)))
+	})
+	if end < 0 {
+		return s
+	}
+	return s[:end]
+}
+
+// parseTag returns the tag in word, without its #, and the bytes of word the
+// tag facet covers. Tags of only digits, like #1, and the keycap emoji #️⃣ are
+// not tags.
+func parseTag(word string) (string, int, bool) {
+	var rest string
+	switch {
+	case strings.HasPrefix(word, "#"):
+		rest = word[1:]
+	case strings.HasPrefix(word, "\uff03"): // fullwidth #
+		rest = word[len("\uff03"):]
+	default:
+		return "", 0, false
+	}
+	if strings.HasPrefix(rest, "\ufe0f") || strings.HasPrefix(rest, "\u20e3") {
+		return "", 0, false
+	}
+
+	tag := strings.TrimRightFunc(rest, unicode.IsPunct)
+	if !strings.ContainsFunc(tag, func(r rune) bool { return !unicode.IsDigit(r) && !unicode.IsPunct(r) }) {
+		return "", 0, false
+	}
+	if graphemeLength(tag) > bskyMaxTagGraphemes {
+		return "", 0, false
+	}
+	return tag, len(word) - len(rest) + len(tag), true
+}
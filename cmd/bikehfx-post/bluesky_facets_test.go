@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+type mapHandleResolver map[string]string
+
+func (m mapHandleResolver) resolveHandle(ctx context.Context, handle string) (string, error) {
+	did, ok := m[handle]
+	if !ok {
+		return "", errors.New("unknown handle")
+	}
+	return did, nil
+}
+
+func TestBuildFacets(t *testing.T) {
+	t.Parallel()
+
+	resolver := mapHandleResolver{"hfx.bike": "did:plc:hfx", "stats.hfx.bike": "did:plc:stats"}
+
+	cases := []struct {
+		name string
+		text string
+		want []string // "text|feature"
+	}{
+		{"Empty", "", nil},
+		{"Hashtag", "579 #BikeHfx bikes counted", []string{"#BikeHfx|tag:BikeHfx"}},
+		{"HashtagAfterEmoji", "🚲🚲 #BikeHfx", []string{"#BikeHfx|tag:BikeHfx"}},
+		{"HashtagNonASCII", "Très #vélo, aujourd'hui", []string{"#vélo|tag:vélo"}},
+		{"HashtagCombiningMark", "#cafe\u0301 ok", []string{"#cafe\u0301|tag:cafe\u0301"}},
+		{"HashtagFullwidth", "＃BikeHfx", []string{"＃BikeHfx|tag:BikeHfx"}},
+		{"HashtagPunctuation", "(#BikeHfx!) #ride.", []string{"#BikeHfx|tag:BikeHfx", "#ride|tag:ride"}},
+		{"NotHashtags", "#1 a#b #\ufe0f\u20e3 # #!!", nil},
+		{"HashtagTooLong", "#" + strings.Repeat("a", 65), nil},
+		{
+			"Link",
+			"See https://hfx.bike/records/, or (https://en.wikipedia.org/wiki/Bicycle_(disambiguation)).",
+			[]string{
+				"https://hfx.bike/records/|link:https://hfx.bike/records/",
+				"https://en.wikipedia.org/wiki/Bicycle_(disambiguation)|link:https://en.wikipedia.org/wiki/Bicycle_(disambiguation)",
+			},
+		},
+		{"LinkWithEmoji", "📈 https://hfx.bike/#daily", []string{"https://hfx.bike/#daily|link:https://hfx.bike/#daily"}},
+		{"NotLinks", "https:// http:/x hfx.bike", nil},
+		{
+			"Mention",
+			"Thanks @hfx.bike. and (@stats.hfx.bike)",
+			[]string{"@hfx.bike|mention:did:plc:hfx", "@stats.hfx.bike|mention:did:plc:stats"},
+		},
+		{"MentionUnresolved", "@unknown.example hi", nil},
+		{"NotMentions", "@ @nodot a@hfx.bike @danp@mastodon.social", nil},
+		{
+			"Mixed",
+			"🇨🇦 @hfx.bike #BikeHfx https://hfx.bike",
+			[]string{"@hfx.bike|mention:did:plc:hfx", "#BikeHfx|tag:BikeHfx", "https://hfx.bike|link:https://hfx.bike"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var got []string
+			for _, f := range buildFacets(context.Background(), tc.text, resolver) {
+				s := tc.text[f.Index.ByteStart:f.Index.ByteEnd] + "|"
+				feat := f.Features[0]
+				switch {
+				case feat.RichtextFacet_Tag != nil:
+					s += "tag:" + feat.RichtextFacet_Tag.Tag
+				case feat.RichtextFacet_Link != nil:
+					s += "link:" + feat.RichtextFacet_Link.Uri
+				case feat.RichtextFacet_Mention != nil:
+					s += "mention:" + feat.RichtextFacet_Mention.Did
+				default:
+					s += fmt.Sprint(feat)
+				}
+				got = append(got, s)
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/bluesky-social/indigo/api/atproto"
+	"github.com/bluesky-social/indigo/util"
+	"github.com/bluesky-social/indigo/xrpc"
+	"github.com/graxinc/errutil"
+)
+
+// blueskySession is the session kept between runs so each one does not need
+// to log in with the password.
+type blueskySession struct {
+	Host       string `json:"host"`
+	Handle     string `json:"handle"`
+	Did        string `json:"did"`
+	AccessJwt  string `json:"access_jwt"`
+	RefreshJwt string `json:"refresh_jwt"`
+}
+
+// newBlueskyPoster reuses the session at sessionPath if there is one for
+// handle on clientHost, refreshing it as needed, and otherwise logs in with
+// password.
+func newBlueskyPoster(clientHost, handle, password, sessionPath string) (blueskyPoster, error) {
+	ctx := context.Background()
+
+	b := blueskyPoster{
+		client: &xrpc.Client{
+			Client: util.RobustHTTPClient(),
+			Host:   clientHost,
+			Auth:   &xrpc.AuthInfo{Handle: handle},
+		},
+		sessionPath: sessionPath,
+	}
+
+	if s, ok, err := loadBlueskySession(sessionPath); err != nil {
+		return blueskyPoster{}, errutil.With(err)
+	} else if ok && s.Host == clientHost && s.Handle == handle {
+		b.client.Auth = &xrpc.AuthInfo{AccessJwt: s.AccessJwt, RefreshJwt: s.RefreshJwt, Did: s.Did, Handle: s.Handle}
+
+		err := b.do(ctx, func(c *xrpc.Client) error {
+			_, err := atproto.ServerGetSession(ctx, c)
+			return err
+		})
+		if err == nil {
+			return b, nil
+		}
+		fmt.Println("could not reuse bluesky session, logging in:", err)
+	}
+
+	auth, err := atproto.ServerCreateSession(ctx, b.client, &atproto.ServerCreateSession_Input{
+		Identifier: handle,
+		Password:   password,
+	})
+	if err != nil {
+		return blueskyPoster{}, errutil.With(err)
+	}
+	b.client.Auth = &xrpc.AuthInfo{AccessJwt: auth.AccessJwt, RefreshJwt: auth.RefreshJwt, Did: auth.Did, Handle: auth.Handle}
+
+	if err := b.saveSession(); err != nil {
+		return blueskyPoster{}, errutil.With(err)
+	}
+	return b, nil
+}
+
+// do calls f, refreshing the session and calling f again if the access token
+// had expired.
+func (b blueskyPoster) do(ctx context.Context, f func(*xrpc.Client) error) error {
+	err := f(b.client)
+	if !isExpiredToken(err) {
+		return err
+	}
+
+	if err := b.refreshSession(ctx); err != nil {
+		return errutil.With(err)
+	}
+	return f(b.client)
+}
+
+func (b blueskyPoster) refreshSession(ctx context.Context) error {
+	// Refreshing authenticates with the refresh token instead.
+	rc := *b.client
+	rc.Auth = &xrpc.AuthInfo{AccessJwt: b.client.Auth.RefreshJwt}
+
+	out, err := atproto.ServerRefreshSession(ctx, &rc)
+	if err != nil {
+		return errutil.With(err)
+	}
+	*b.client.Auth = xrpc.AuthInfo{AccessJwt: out.AccessJwt, RefreshJwt: out.RefreshJwt, Did: out.Did, Handle: out.Handle}
+
+	fmt.Println("refreshed bluesky session")
+	return b.saveSession()
+}
+
+func isExpiredToken(err error) bool {
+	var xe *xrpc.XRPCError
+	return errors.As(err, &xe) && xe.ErrStr == "ExpiredToken"
+}
+
+func (b blueskyPoster) saveSession() error {
+	if b.sessionPath == "" {
+		return nil
+	}
+	s := blueskySession{
+		Host:       b.client.Host,
+		Handle:     b.client.Auth.Handle,
+		Did:        b.client.Auth.Did,
+		AccessJwt:  b.client.Auth.AccessJwt,
+		RefreshJwt: b.client.Auth.RefreshJwt,
+	}
+	bs, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return errutil.With(err)
+	}
+	return writeFileAtomic(b.sessionPath, bs)
+}
+
+func loadBlueskySession(path string) (blueskySession, bool, error) {
+	if path == "" {
+		return blueskySession{}, false, nil
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return blueskySession{}, false, nil
+	}
+	if err != nil {
+		return blueskySession{}, false, errutil.With(err)
+	}
+
+	var s blueskySession
+	if err := json.Unmarshal(b, &s); err != nil {
+		return blueskySession{}, false, errutil.With(err)
+	}
+	return s, true, nil
+}
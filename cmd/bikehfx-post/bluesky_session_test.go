@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeXRPCServer is a stand-in for a Bluesky PDS that issues numbered tokens
+// and can expire them.
+type fakeXRPCServer struct {
+	mu        sync.Mutex
+	n         int
+	access    string
+	refresh   string
+	logins    int
+	refreshes int
+	records   int
+}
+
+func (s *fakeXRPCServer) issue() map[string]string {
+	s.n++
+	s.access = fmt.Sprintf("access-%d", s.n)
+	s.refresh = fmt.Sprintf("refresh-%d", s.n)
+	return map[string]string{"accessJwt": s.access, "refreshJwt": s.refresh, "did": "did:plc:stats", "handle": "stats.hfx.bike"}
+}
+
+// expire makes the current access token, and optionally the refresh token,
+// stop working.
+func (s *fakeXRPCServer) expire(refresh bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.access = "expired"
+	if refresh {
+		s.refresh = "expired"
+	}
+}
+
+func (s *fakeXRPCServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bearer := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	expired := func() {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "ExpiredToken", "message": "Token has expired"})
+	}
+	reply := func(v any) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(v)
+	}
+
+	switch strings.TrimPrefix(r.URL.Path, "/xrpc/") {
+	case "com.atproto.server.createSession":
+		s.logins++
+		reply(s.issue())
+	case "com.atproto.server.refreshSession":
+		if bearer != s.refresh {
+			expired()
+			return
+		}
+		s.refreshes++
+		reply(s.issue())
+	case "com.atproto.server.getSession":
+		if bearer != s.access {
+			expired()
+			return
+		}
+		reply(map[string]string{"did": "did:plc:stats", "handle": "stats.hfx.bike"})
+	case "com.atproto.repo.createRecord":
+		if bearer != s.access {
+			expired()
+			return
+		}
+		s.records++
+		reply(map[string]string{"uri": fmt.Sprintf("at://did:plc:stats/app.bsky.feed.post/%d", s.records), "cid": "cid"})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestBlueskySession(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	fs := &fakeXRPCServer{}
+	srv := httptest.NewServer(fs)
+	t.Cleanup(srv.Close)
+
+	path := filepath.Join(t.TempDir(), "bluesky-session.json")
+
+	if _, err := newBlueskyPoster(srv.URL, "stats.hfx.bike", "pw", path); err != nil {
+		t.Fatal(err)
+	}
+	if fs.logins != 1 {
+		t.Fatalf("logins = %d, want 1", fs.logins)
+	}
+
+	// The saved session is reused.
+	b, err := newBlueskyPoster(srv.URL, "stats.hfx.bike", "pw", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fs.logins != 1 {
+		t.Fatalf("logins = %d after reuse, want 1", fs.logins)
+	}
+
+	// An expired access token is refreshed and the post retried once.
+	fs.expire(false)
+	if _, err := b.post(ctx, post{text: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	if fs.refreshes != 1 || fs.records != 1 || fs.logins != 1 {
+		t.Fatalf("refreshes = %d, records = %d, logins = %d, want 1 each", fs.refreshes, fs.records, fs.logins)
+	}
+
+	s, ok, err := loadBlueskySession(path)
+	if err != nil || !ok {
+		t.Fatal(ok, err)
+	}
+	if s.AccessJwt != fs.access || s.RefreshJwt != fs.refresh {
+		t.Errorf("saved session %+v, want refreshed tokens %s and %s", s, fs.access, fs.refresh)
+	}
+
+	// A new run refreshes the saved session rather than logging in.
+	fs.expire(false)
+	if _, err := newBlueskyPoster(srv.URL, "stats.hfx.bike", "pw", path); err != nil {
+		t.Fatal(err)
+	}
+	if fs.refreshes != 2 || fs.logins != 1 {
+		t.Fatalf("refreshes = %d, logins = %d, want 2 and 1", fs.refreshes, fs.logins)
+	}
+
+	// Once the refresh token is no good either, it logs in again.
+	fs.expire(true)
+	if _, err := newBlueskyPoster(srv.URL, "stats.hfx.bike", "pw", path); err != nil {
+		t.Fatal(err)
+	}
+	if fs.logins != 2 {
+		t.Fatalf("logins = %d, want 2", fs.logins)
+	}
+
+	// A session for another handle is not used.
+	if _, err := newBlueskyPoster(srv.URL, "other.hfx.bike", "pw", path); err != nil {
+		t.Fatal(err)
+	}
+	if fs.logins != 3 {
+		t.Fatalf("logins = %d, want 3", fs.logins)
+	}
+}
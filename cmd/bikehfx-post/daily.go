@@ -26,7 +26,7 @@ func newDailyCmd(rootConfig *rootConfig) *ffcli.Command {
 		day  = fs.String("day", time.Now().AddDate(0, 0, -1).Format("20060102"), "day to post for, in YYYYMMDD form")
 		days commaSeparatedString
 	)
-	fs.Var(&days, "days", "comma-separated days to post, in YYYYMMDD form, preferred over day")
+	fs.Var(&days, "days", "comma-separated days to post, each as its own thread, in YYYYMMDD form, preferred over day")
 
 	return &ffcli.Command{
 		Name:       "daily",
@@ -38,33 +38,37 @@ func newDailyCmd(rootConfig *rootConfig) *ffcli.Command {
 			if len(days) == 0 {
 				days = []string{*day}
 			}
-			return dailyExec(ctx, days, rootConfig.trq, rootConfig.rc, rootConfig.tp)
+			return dailyExec(ctx, days, rootConfig.trq, rootConfig.wr, rootConfig.hw, rootConfig.rc, rootConfig.cm, rootConfig.weather.coordinates(), rootConfig.tp, rootConfig.postedRecords())
 		},
 	}
 }
 
-func dailyExec(ctx context.Context, days []string, trq counterbaseTimeRangeQuerier, rc recordser, tp threadPoster) error {
+func dailyExec(ctx context.Context, days []string, trq counterbaseTimeRangeQuerier, wr weatherer, hw hourlyWeatherer, rc recordser, cm *countModel, coords coordinates, tp threadPoster, rj *recordJournal) error {
 	loc, err := time.LoadLocation("America/Halifax")
 	if err != nil {
 		return errutil.With(err)
 	}
+	if cm == nil {
+		log.Println("expected count model has not been fit, leaving out expected counts; see bikehfx-post model -h")
+	}
 
-	var posts []post
+	var threads []periodThread
 	for _, day := range days {
 		dayt, err := time.ParseInLocation("20060102", day, loc)
 		if err != nil {
 			return errutil.With(err)
 		}
 
-		ps, err := dayPost(ctx, dayt, trq, ecWeatherer{}, rc, uvScriptHeatmaper{})
+		key := threadKey{kind: "daily", period: dayt.Format("20060102")}
+		threads, err = addPeriodThread(threads, key, func() ([]post, []recordJournalEntry, error) {
+			return dayPost(ctx, dayt, trq, wr, hw, rc, cm, coords, uvScriptHeatmaper{})
+		})
 		if err != nil {
 			return errutil.With(err)
 		}
-
-		posts = append(posts, ps...)
 	}
 
-	if _, err := tp.postThread(ctx, posts); err != nil {
+	if err := postPeriodThreads(ctx, tp, rj, threads); err != nil {
 		return errutil.With(err)
 	}
 	return nil
@@ -75,7 +79,7 @@ type weatherer interface {
 }
 
 type dayHeatmaper interface {
-	heatmap(ctx context.Context, day time.Time, cs []counterSeries) (_ []byte, altText string, _ error)
+	heatmap(ctx context.Context, day time.Time, cs []counterSeries, hours []hourWeather, sun sunDay) (_ []byte, altText string, _ error)
 }
 
 type counterSeries struct {
@@ -86,12 +90,12 @@ type counterSeries struct {
 	series      []timeRangeValue
 }
 
-func dayPost(ctx context.Context, day time.Time, trq counterbaseTimeRangeQuerier, weatherer weatherer, recordser recordser, heatmaper dayHeatmaper) ([]post, error) {
+func dayPost(ctx context.Context, day time.Time, trq counterbaseTimeRangeQuerier, weatherer weatherer, hourlyWeatherer hourlyWeatherer, recordser recordser, model *countModel, coords coordinates, heatmaper dayHeatmaper) ([]post, []recordJournalEntry, error) {
 	dayRange := newTimeRangeDate(time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location()), 0, 0, 1)
 
 	cs, err := trq.query(ctx, dayRange)
 	if err != nil {
-		return nil, errutil.With(err)
+		return nil, nil, errutil.With(err)
 	}
 
 	var anyBikes bool
@@ -105,31 +109,44 @@ func dayPost(ctx context.Context, day time.Time, trq counterbaseTimeRangeQuerier
 	}
 	if !anyBikes {
 		log.Printf("no bikes counted on %v", day)
-		return nil, nil
+		return nil, nil, nil
 	}
 
-	records, err := recordser.records(ctx, day, cs, recordWidthDay)
+	records, broken, err := recordser.records(ctx, day, cs, recordWidthDay)
 	if err != nil {
-		return nil, errutil.With(err)
+		return nil, nil, errutil.With(err)
 	}
 
 	w, err := weatherer.weather(ctx, day)
+	haveWeather := err == nil
 	if err != nil {
 		log.Printf("weatherer.weather: %v", err)
 		w = weather{}
 	}
 
-	text := dayPostText(day, w, cs, records)
+	var hours []hourWeather
+	if hourlyWeatherer != nil {
+		hours, err = hourlyWeatherer.hourlyWeather(ctx, day)
+		if err != nil {
+			log.Printf("hourlyWeatherer.hourlyWeather: %v", err)
+			hours = nil
+		}
+	}
+	w = w.withHourly(hours)
+
+	sun := coords.sun(day)
+
+	text := dayPostText(day, w, sun, model.dayExpectation(day, w, haveWeather, cs), cs, records)
 
 	dayHours := dayRange.split(time.Hour)
 	hourSeries, err := trq.query(ctx, dayHours...)
 	if err != nil {
-		return nil, errutil.With(err)
+		return nil, nil, errutil.With(err)
 	}
 
-	dg, dat, err := heatmaper.heatmap(ctx, day, hourSeries)
+	dg, dat, err := heatmaper.heatmap(ctx, day, hourSeries, hours, sun)
 	if err != nil {
-		return nil, errutil.With(err)
+		return nil, nil, errutil.With(err)
 	}
 
 	media := []postMedia{{
@@ -141,10 +158,10 @@ func dayPost(ctx context.Context, day time.Time, trq counterbaseTimeRangeQuerier
 	if statusPostText := counterStatusPostText(day, cs); statusPostText != "" {
 		posts = append(posts, post{text: statusPostText})
 	}
-	return posts, nil
+	return posts, broken, nil
 }
 
-func dayPostText(day time.Time, w weather, cs []counterSeries, records map[string]recordKind) string {
+func dayPostText(day time.Time, w weather, sun sunDay, expectation dayExpectation, cs []counterSeries, records map[string]recordKind) string {
 	var out strings.Builder
 
 	p := message.NewPrinter(language.English)
@@ -164,14 +181,34 @@ func dayPostText(day time.Time, w weather, cs []counterSeries, records map[strin
 	if w.max != 0 {
 		p.Fprintf(&out, "%v/%v C", int(math.Ceil(w.max)), int(math.Floor(w.min)))
 		if w.rain > 0 {
-			raindrop := "\U0001f4a7"
-			p.Fprintf(&out, " %v %.1fmm", raindrop, w.rain)
+			p.Fprintf(&out, " %v %.1fmm", weatherRaindrop, w.rain)
 		}
 		if w.snow > 0 {
-			snowflake := "\u2744\ufe0f"
-			p.Fprintf(&out, " %v %.1fcm", snowflake, w.snow)
+			p.Fprintf(&out, " %v %.1fcm", weatherSnowflake, w.snow)
+		}
+		if w.gust > 0 {
+			p.Fprintf(&out, " %v", weatherWind)
+			if w.gustDir > 0 {
+				p.Fprintf(&out, " %v", compassPoint(w.gustDir))
+			}
+			p.Fprintf(&out, " %vkm/h", int(math.Round(w.gust)))
+		}
+		if w.windChill != 0 {
+			p.Fprintf(&out, " %v %v", weatherCold, int(math.Round(w.windChill)))
+		}
+		if w.humidex != 0 {
+			p.Fprintf(&out, " %v %v", weatherHot, int(math.Round(w.humidex)))
+		}
+		if t := expectation.text(); t != "" {
+			p.Fprintf(&out, "\n%v", t)
 		}
-		p.Fprintf(&out, "\n\n")
+		p.Fprintln(&out)
+	}
+	if t := sun.text(); t != "" {
+		p.Fprintln(&out, t)
+	}
+	if w.max != 0 || sun.ok() {
+		p.Fprintln(&out)
 	}
 
 	slices.SortFunc(presentIndices, func(i, j int) int {
@@ -195,6 +232,12 @@ func counterStatusSymbol(status counterDataStatus) string {
 	return ""
 }
 
+// Notes written by appendPostMarkerNotes, which splitPost also recognizes.
+const (
+	sumRecordNotePrefix = "Total record compares only"
+	partialDataNote     = "! partial data"
+)
+
 func appendPostMarkerNotes(out *strings.Builder, records map[string]recordKind, cs []counterSeries) {
 	recordKinds := make(map[recordKind]struct{})
 	for _, k := range records {
@@ -211,8 +254,19 @@ func appendPostMarkerNotes(out *strings.Builder, records map[string]recordKind,
 	for _, k := range slices.Sorted(maps.Keys(recordKinds)) {
 		p.Fprintln(out, recordNote(k))
 	}
+	if _, ok := records["sum"]; ok {
+		var summed int
+		for _, c := range cs {
+			if countsTowardSum(c) {
+				summed++
+			}
+		}
+		if summed < len(cs) {
+			p.Fprintf(out, "%s the %d of %d counters reporting\n", sumRecordNotePrefix, summed, len(cs))
+		}
+	}
 	if hasPartialData {
-		p.Fprintln(out, "! partial data")
+		p.Fprintln(out, partialDataNote)
 	}
 }
 
@@ -276,7 +330,7 @@ func counterLastStatusTime(c counterSeries) time.Time {
 
 type uvScriptHeatmaper struct{}
 
-func (uvScriptHeatmaper) heatmap(ctx context.Context, day time.Time, cs []counterSeries) ([]byte, string, error) {
+func (uvScriptHeatmaper) heatmap(ctx context.Context, day time.Time, cs []counterSeries, hours []hourWeather, sun sunDay) ([]byte, string, error) {
 	hourOrder := make([]string, 0, 24)
 	for i := 0; i < 24; i++ {
 		hourOrder = append(hourOrder, fmt.Sprintf("%02d", i))
@@ -311,14 +365,52 @@ func (uvScriptHeatmaper) heatmap(ctx context.Context, day time.Time, cs []counte
 		})
 	}
 
+	input.WeatherRow = heatmapWeatherRow(hours)
+	if sun.ok() {
+		for _, h := range newTimeRangeDate(day, 0, 0, 1).split(time.Hour) {
+			if sun.dark(h.begin) {
+				input.DarkXValues = append(input.DarkXValues, fmt.Sprintf("%02d", h.begin.Hour()))
+			}
+		}
+	}
+
 	imgBytes, err := runUVScript(ctx, "heatmap.py", input)
 	if err != nil {
 		return nil, "", errutil.With(err)
 	}
-	return imgBytes, dailyAltText(cs), nil
+	altText := dailyAltText(cs, hours)
+	if altText != "" && len(input.DarkXValues) > 0 {
+		altText += " Hours before sunrise and after sunset are shaded."
+	}
+	return imgBytes, altText, nil
 }
 
-func dailyAltText(cs []counterSeries) string {
+// heatmapWeatherRow shows hourly precipitation if there was any, otherwise
+// hourly temperature.
+func heatmapWeatherRow(hours []hourWeather) *heatmapInputWeatherRow {
+	anyPrecip := slices.ContainsFunc(hours, func(h hourWeather) bool { return h.precip > 0 })
+
+	row := &heatmapInputWeatherRow{Name: "Temp (C)", ColorMap: "coolwarm"}
+	if anyPrecip {
+		row = &heatmapInputWeatherRow{Name: "Rain (mm)", ColorMap: "Blues", Precision: 1}
+	}
+	for _, h := range hours {
+		if !anyPrecip && !h.hasTemp {
+			continue
+		}
+		v := heatmapInputWeatherValue{X: fmt.Sprintf("%02d", h.at.Hour()), Value: h.temp}
+		if anyPrecip {
+			v.Value = h.precip
+		}
+		row.Values = append(row.Values, v)
+	}
+	if len(row.Values) == 0 {
+		return nil
+	}
+	return row
+}
+
+func dailyAltText(cs []counterSeries, hours []hourWeather) string {
 	if len(cs) == 0 {
 		return ""
 	}
@@ -372,9 +464,42 @@ func dailyAltText(cs []counterSeries) string {
 		}
 		out += fmt.Sprintf(" The highest hourly count was %d from the %s %s.", hhs[0].series[0].val, humanList(hcn), counter)
 	}
+
+	out += rainDropAltText(cs, hours)
 	return out
 }
 
+// rainDropAltText notes rain hours where the total count fell by at least a
+// quarter from the hour before.
+func rainDropAltText(cs []counterSeries, hours []hourWeather) string {
+	totals := make(map[time.Time]int)
+	for _, c := range cs {
+		for _, trv := range c.series {
+			totals[trv.tr.begin] += trv.val
+		}
+	}
+
+	var drops []string
+	for _, h := range hours {
+		if !h.rainy() {
+			continue
+		}
+		prev, cur := totals[h.at.Add(-time.Hour)], totals[h.at]
+		if prev == 0 || cur*4 > prev*3 {
+			continue
+		}
+		drops = append(drops, h.at.Format("3 PM"))
+	}
+	switch len(drops) {
+	case 0:
+		return ""
+	case 1:
+		return fmt.Sprintf(" Counts dropped during rain in the %s hour.", drops[0])
+	default:
+		return fmt.Sprintf(" Counts dropped during rain in the %s hours.", humanList(drops))
+	}
+}
+
 // adapted from https://github.com/dustin/go-humanize/blob/master/english/words.go
 func humanList(words []string) string {
 	const joiner = " and "
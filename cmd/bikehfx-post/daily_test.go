@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
@@ -57,6 +58,8 @@ func TestDayPostText(t *testing.T) {
 		w := weather{
 			max: 30.111, min: 20.222,
 			rain: 1.234, snow: 2.345,
+			gust: 57, gustDir: 230,
+			humidex: 36.4,
 		}
 		cs := []counterSeries{
 			makeSeries("b", "Banana", 456),
@@ -71,7 +74,40 @@ func TestDayPostText(t *testing.T) {
 			"b":   recordKindYTD,
 		}
 
-		got := dayPostText(day, w, cs, records)
+		got := dayPostText(day, w, sunDay{}, dayExpectation{actual: 579, expected: 517}, cs, records)
+		expect(t, "text.txt", got)
+	})
+
+	t.Run("WindChill", func(t *testing.T) {
+		cs := []counterSeries{
+			makeSeries("a", "Apple", 45),
+		}
+		w := weather{max: -3, min: -11.5, snow: 4, gust: 74, gustDir: 360, windChill: -22.6}
+		got := dayPostText(day, w, sunDay{}, dayExpectation{}, cs, nil)
+		expect(t, "text.txt", got)
+	})
+
+	t.Run("FileWeather", func(t *testing.T) {
+		loc, err := time.LoadLocation("America/Halifax")
+		if err != nil {
+			t.Fatal(err)
+		}
+		localDay := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, loc)
+
+		fw := newFileWeatherer(filepath.Join("testdata", "weather"))
+		w, err := fw.weather(context.Background(), localDay)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hours, err := fw.hourlyWeather(context.Background(), localDay)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cs := []counterSeries{
+			makeSeries("a", "Apple", 321),
+		}
+		got := dayPostText(localDay, w.withHourly(hours), coordinates{latitude: 44.6488, longitude: -63.5752}.sun(localDay), dayExpectation{}, cs, nil)
 		expect(t, "text.txt", got)
 	})
 
@@ -79,7 +115,7 @@ func TestDayPostText(t *testing.T) {
 		cs := []counterSeries{
 			makeSeries("a", "Apple", 123),
 		}
-		got := dayPostText(day, weather{}, cs, nil)
+		got := dayPostText(day, weather{}, sunDay{}, dayExpectation{}, cs, nil)
 		expect(t, "text.txt", got)
 	})
 }
@@ -103,6 +139,29 @@ func TestCounterStatusPostText(t *testing.T) {
 	}
 }
 
+func TestDailyAltTextRainDrop(t *testing.T) {
+	t.Parallel()
+
+	hour := func(h, val int) timeRangeValue {
+		begin := time.Date(2023, 7, 21, h, 0, 0, 0, time.UTC)
+		return timeRangeValue{tr: timeRange{begin: begin, end: begin.Add(time.Hour)}, val: val}
+	}
+	cs := []counterSeries{
+		{counter: directory.Counter{ID: "a", Name: "Apple"}, series: []timeRangeValue{hour(14, 80), hour(15, 30), hour(16, 70), hour(17, 65)}},
+		{counter: directory.Counter{ID: "b", Name: "Banana"}, series: []timeRangeValue{hour(14, 20), hour(15, 10), hour(16, 30), hour(17, 30)}},
+	}
+	hours := []hourWeather{
+		{at: time.Date(2023, 7, 21, 15, 0, 0, 0, time.UTC), precip: 3.1},
+		// Rain without a drop in counts.
+		{at: time.Date(2023, 7, 21, 17, 0, 0, 0, time.UTC), desc: "Drizzle"},
+	}
+
+	got := dailyAltText(cs, hours)
+	if want := " Counts dropped during rain in the 3 PM hour."; !strings.HasSuffix(got, want) {
+		t.Errorf("dailyAltText() = %q, want suffix %q", got, want)
+	}
+}
+
 var unsafeNameRe = regexp.MustCompile(`[^-.\w/]+`)
 
 func expect(t testing.TB, filename string, got any) {
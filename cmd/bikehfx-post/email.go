@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/graxinc/errutil"
+)
+
+// emailThreader sends each thread of the configured kinds as one email, with
+// an HTML body showing charts inline and a plain text alternative. Sent
+// emails are recorded in journal, if set, so reruns do not send them again
+// unless force is set.
+type emailThreader struct {
+	addr     string // host:port of the SMTP server
+	tls      string // starttls, tls for implicit TLS, or none
+	username string
+	password string
+	from     string
+	to       []string
+	kinds    []string
+	timeout  time.Duration
+	journal  *postJournal
+	force    bool
+}
+
+func validateEmailTLS(v string) error {
+	switch v {
+	case "starttls", "tls", "none":
+		return nil
+	}
+	return errutil.New(errutil.Tags{"msg": "invalid email TLS mode", "tls": v})
+}
+
+func (e *emailThreader) postThread(ctx context.Context, key threadKey, posts []post) ([]threadResult, error) {
+	if len(posts) == 0 || !slices.Contains(e.kinds, key.kind) {
+		return nil, nil
+	}
+
+	if e.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.timeout)
+		defer cancel()
+	}
+
+	id, err := e.sendThread(ctx, key, posts)
+	if err != nil {
+		err = errutil.Witht(err, errutil.Tags{"platform": "email"})
+		return []threadResult{{platform: "email", err: err}}, err
+	}
+	return []threadResult{{platform: "email", ids: []string{id}}}, nil
+}
+
+// sendThread emails posts unless the journal shows they were already sent,
+// returning the Message-ID.
+func (e *emailThreader) sendThread(ctx context.Context, key threadKey, posts []post) (string, error) {
+	if e.journal != nil && !e.force {
+		j, ok, err := e.journal.lookup(key, "email")
+		if err != nil {
+			return "", errutil.With(err)
+		}
+		if ok && len(j.IDs) > 0 {
+			fmt.Println("already emailed", key.kind, key.period, "at", j.PostedAt.Format(time.RFC3339), "skipping, use -force to resend")
+			return j.IDs[0], nil
+		}
+	}
+
+	msg, id, err := e.message(key, posts, time.Now())
+	if err != nil {
+		return "", errutil.With(err)
+	}
+	if err := e.send(ctx, msg); err != nil {
+		return "", errutil.With(err)
+	}
+	fmt.Println("email sent", id)
+
+	if e.journal != nil {
+		err := e.journal.record(postJournalEntry{
+			Kind:     key.kind,
+			Period:   key.period,
+			Platform: "email",
+			IDs:      []string{id},
+			Hash:     threadHash(posts),
+			PostedAt: time.Now().UTC(),
+		})
+		if err != nil {
+			return id, errutil.With(err)
+		}
+	}
+	return id, nil
+}
+
+// message renders posts as an email, returning it and its Message-ID.
+func (e *emailThreader) message(key threadKey, posts []post, now time.Time) ([]byte, string, error) {
+	idb := make([]byte, 12)
+	if _, err := rand.Read(idb); err != nil {
+		return nil, "", errutil.With(err)
+	}
+	// Addresses are written back out so display names are encoded.
+	from, err := mail.ParseAddress(e.from)
+	if err != nil {
+		return nil, "", errutil.With(err)
+	}
+	to := make([]string, 0, len(e.to))
+	for _, t := range e.to {
+		a, err := mail.ParseAddress(t)
+		if err != nil {
+			return nil, "", errutil.With(err)
+		}
+		to = append(to, a.String())
+	}
+	_, domain, _ := strings.Cut(from.Address, "@")
+	id := hex.EncodeToString(idb) + "@" + domain
+
+	// Post text starts the same for every period, so name it instead.
+	subject := "BikeHfx " + key.title()
+
+	var text, body strings.Builder
+	body.WriteString("<!DOCTYPE html>\n<html><body>\n")
+	type inline struct {
+		cid  string
+		mime string
+		b    []byte
+	}
+	var images []inline
+	for pi, p := range posts {
+		if pi > 0 {
+			text.WriteString("\n\n")
+		}
+		text.WriteString(p.text)
+		body.WriteString("<p>" + strings.ReplaceAll(html.EscapeString(p.text), "\n", "<br>\n") + "</p>\n")
+
+		for mi, m := range p.media {
+			am, err := adaptMedia(m.b, mediaLimits{})
+			if err != nil {
+				return nil, "", errutil.With(err)
+			}
+			cid := fmt.Sprintf("image-%02d-%02d.%s", pi, mi, id)
+			images = append(images, inline{cid: cid, mime: am.mimeType, b: am.b})
+
+			fmt.Fprintf(&text, "\n\n[Image: %s]", m.altText)
+			fmt.Fprintf(&body, "<p><img src=\"cid:%s\" alt=\"%s\" style=\"max-width: 100%%\"></p>\n", html.EscapeString(cid), html.EscapeString(m.altText))
+		}
+	}
+	body.WriteString("</body></html>\n")
+
+	var buf bytes.Buffer
+	alt := multipart.NewWriter(&buf)
+	fmt.Fprintf(&buf, "From: %s\r\n", from.String())
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%s>\r\n", id)
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", alt.Boundary())
+
+	if err := writeQuotedPart(alt, "text/plain; charset=utf-8", text.String()); err != nil {
+		return nil, "", errutil.With(err)
+	}
+
+	relatedPart := &bytes.Buffer{}
+	related := multipart.NewWriter(relatedPart)
+	if err := writeQuotedPart(related, "text/html; charset=utf-8", body.String()); err != nil {
+		return nil, "", errutil.With(err)
+	}
+	for _, img := range images {
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Type", img.mime)
+		h.Set("Content-Transfer-Encoding", "base64")
+		h.Set("Content-ID", "<"+img.cid+">")
+		h.Set("Content-Disposition", "inline")
+		w, err := related.CreatePart(h)
+		if err != nil {
+			return nil, "", errutil.With(err)
+		}
+		if err := writeBase64Lines(w, img.b); err != nil {
+			return nil, "", errutil.With(err)
+		}
+	}
+	if err := related.Close(); err != nil {
+		return nil, "", errutil.With(err)
+	}
+
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Type", "multipart/related; boundary="+related.Boundary())
+	w, err := alt.CreatePart(h)
+	if err != nil {
+		return nil, "", errutil.With(err)
+	}
+	if _, err := w.Write(relatedPart.Bytes()); err != nil {
+		return nil, "", errutil.With(err)
+	}
+	if err := alt.Close(); err != nil {
+		return nil, "", errutil.With(err)
+	}
+
+	return buf.Bytes(), id, nil
+}
+
+func writeQuotedPart(mw *multipart.Writer, contentType, s string) error {
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Type", contentType)
+	h.Set("Content-Transfer-Encoding", "quoted-printable")
+	w, err := mw.CreatePart(h)
+	if err != nil {
+		return errutil.With(err)
+	}
+	qw := quotedprintable.NewWriter(w)
+	if _, err := io.WriteString(qw, s); err != nil {
+		return errutil.With(err)
+	}
+	return qw.Close()
+}
+
+// writeBase64Lines writes b as base64 in 76 character lines, as email
+// requires.
+func writeBase64Lines(w io.Writer, b []byte) error {
+	s := base64.StdEncoding.EncodeToString(b)
+	for len(s) > 0 {
+		n := min(len(s), 76)
+		if _, err := io.WriteString(w, s[:n]+"\r\n"); err != nil {
+			return errutil.With(err)
+		}
+		s = s[n:]
+	}
+	return nil
+}
+
+func (e *emailThreader) send(ctx context.Context, msg []byte) error {
+	host, _, err := net.SplitHostPort(e.addr)
+	if err != nil {
+		return errutil.With(err)
+	}
+	tlsConfig := &tls.Config{ServerName: host}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", e.addr)
+	if err != nil {
+		return errutil.With(err)
+	}
+	if e.tls == "tls" {
+		conn = tls.Client(conn, tlsConfig)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return errutil.With(err)
+	}
+	defer c.Close()
+
+	if e.tls == "starttls" {
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return errutil.With(err)
+		}
+	}
+	if e.username != "" {
+		if err := c.Auth(smtp.PlainAuth("", e.username, e.password, host)); err != nil {
+			return errutil.With(err)
+		}
+	}
+
+	from, err := mail.ParseAddress(e.from)
+	if err != nil {
+		return errutil.With(err)
+	}
+	if err := c.Mail(from.Address); err != nil {
+		return errutil.With(err)
+	}
+	for _, to := range e.to {
+		a, err := mail.ParseAddress(to)
+		if err != nil {
+			return errutil.Witht(err, errutil.Tags{"to": to})
+		}
+		if err := c.Rcpt(a.Address); err != nil {
+			return errutil.Witht(err, errutil.Tags{"to": to})
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return errutil.With(err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return errutil.With(err)
+	}
+	if err := w.Close(); err != nil {
+		return errutil.With(err)
+	}
+	return c.Quit()
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type smtpMessage struct {
+	auth string
+	from string
+	to   []string
+	data []byte
+}
+
+// newSMTPServer starts a minimal SMTP server on localhost that accepts any
+// message, returning its address and a func for what it received.
+func newSMTPServer(t *testing.T) (string, func() []smtpMessage) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	var (
+		mu   sync.Mutex
+		msgs []smtpMessage
+	)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				tc := textproto.NewConn(conn)
+				tc.PrintfLine("220 localhost ESMTP")
+
+				var m smtpMessage
+				for {
+					line, err := tc.ReadLine()
+					if err != nil {
+						return
+					}
+					verb, arg, _ := strings.Cut(line, " ")
+					switch strings.ToUpper(verb) {
+					case "EHLO", "HELO":
+						tc.PrintfLine("250-localhost")
+						tc.PrintfLine("250 AUTH PLAIN")
+					case "AUTH":
+						_, resp, _ := strings.Cut(arg, " ")
+						b, _ := base64.StdEncoding.DecodeString(resp)
+						m.auth = string(b)
+						tc.PrintfLine("235 ok")
+					case "MAIL":
+						m.from = strings.Trim(strings.TrimPrefix(arg, "FROM:"), "<>")
+						tc.PrintfLine("250 ok")
+					case "RCPT":
+						m.to = append(m.to, strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>"))
+						tc.PrintfLine("250 ok")
+					case "DATA":
+						tc.PrintfLine("354 go ahead")
+						m.data, err = tc.ReadDotBytes()
+						if err != nil {
+							return
+						}
+						mu.Lock()
+						msgs = append(msgs, m)
+						mu.Unlock()
+						m = smtpMessage{}
+						tc.PrintfLine("250 queued")
+					case "QUIT":
+						tc.PrintfLine("221 bye")
+						return
+					default:
+						tc.PrintfLine("502 not implemented")
+					}
+				}
+			}()
+		}
+	}()
+
+	return ln.Addr().String(), func() []smtpMessage {
+		mu.Lock()
+		defer mu.Unlock()
+		return msgs
+	}
+}
+
+func TestEmailThreader(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	addr, msgs := newSMTPServer(t)
+
+	et := &emailThreader{
+		addr:     addr,
+		tls:      "none",
+		username: "stats",
+		password: "secret",
+		from:     "BikeHfx <stats@hfx.bike>",
+		to:       []string{"staff@halifax.ca", "Équipe vélo <other@halifax.ca>"},
+		kinds:    []string{"weekly"},
+		journal:  &postJournal{path: filepath.Join(t.TempDir(), "posts.json")},
+	}
+
+	weekRange := newTimeRangeDate(time.Date(2023, 7, 16, 0, 0, 0, 0, time.UTC), 0, 0, 7)
+	var apple counterSeries
+	apple.counter.ID, apple.counter.Name = "a", "Apple"
+	apple.series = []timeRangeValue{{tr: weekRange, val: 3210}}
+	text := weekPostText(weekRange, weatherSummary{}, []counterSeries{apple}, nil)
+	key := threadKey{kind: "weekly", period: "20230716"}
+
+	// Kinds not configured are skipped.
+	res, err := et.postThread(ctx, threadKey{kind: "daily", period: "20230721"}, []post{{text: "daily"}})
+	if err != nil || len(res) != 0 {
+		t.Fatalf("daily got %v, %v, want nothing", res, err)
+	}
+
+	png := testPNG(t, 40, 20, false)
+	posts := []post{
+		{text: text, media: []postMedia{{b: png, altText: "bar chart of <counts>"}}},
+		{text: "second"},
+	}
+	res, err = et.postThread(ctx, key, posts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0].platform != "email" || len(res[0].ids) != 1 || !strings.HasSuffix(res[0].ids[0], "@hfx.bike") {
+		t.Fatalf("results = %+v, want one email result", res)
+	}
+
+	got := msgs()
+	if len(got) != 1 {
+		t.Fatalf("got %d messages, want 1", len(got))
+	}
+	if got[0].auth != "\x00stats\x00secret" {
+		t.Errorf("auth = %q", got[0].auth)
+	}
+	if got[0].from != "stats@hfx.bike" || strings.Join(got[0].to, ",") != "staff@halifax.ca,other@halifax.ca" {
+		t.Errorf("envelope from %s to %v", got[0].from, got[0].to)
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(got[0].data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every week's text starts "Week review:", so the subject names the week.
+	if subj, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject")); subj != "BikeHfx Week review: week ending Sat Jul 22, 2023" {
+		t.Errorf("subject = %q", subj)
+	}
+	// Display names are encoded like the subject.
+	if to := msg.Header.Get("To"); strings.ContainsFunc(to, func(r rune) bool { return r > 127 }) {
+		t.Errorf("To header not encoded: %s", to)
+	}
+	if to, err := msg.Header.AddressList("To"); err != nil || len(to) != 2 || to[1].Name != "Équipe vélo" || to[1].Address != "other@halifax.ca" {
+		t.Errorf("To = %v, %v", to, err)
+	}
+	if from, err := msg.Header.AddressList("From"); err != nil || len(from) != 1 || from[0].String() != `"BikeHfx" <stats@hfx.bike>` {
+		t.Errorf("From = %v, %v", from, err)
+	}
+	if id := msg.Header.Get("Message-ID"); id != "<"+res[0].ids[0]+">" {
+		t.Errorf("Message-ID = %s, want <%s>", id, res[0].ids[0])
+	}
+
+	parts := readMultipart(t, msg.Header.Get("Content-Type"), msg.Body, "multipart/alternative")
+	if len(parts) != 2 {
+		t.Fatalf("got %d alternative parts, want 2", len(parts))
+	}
+	if want := text + "\n\n[Image: bar chart of <counts>]\n\nsecond"; parts[0].ct != "text/plain" || parts[0].body != want {
+		t.Errorf("plain part %s = %q, want %q", parts[0].ct, parts[0].body, want)
+	}
+
+	related := readMultipart(t, parts[1].header.Get("Content-Type"), strings.NewReader(parts[1].body), "multipart/related")
+	if len(related) != 2 {
+		t.Fatalf("got %d related parts, want 2", len(related))
+	}
+	body := related[0].body
+	for _, want := range []string{"<p>Week review:<br>\n<br>\n3,210 #BikeHfx bikes counted week ending Sat Jul 22<br>\n", "<p>second</p>", `alt="bar chart of &lt;counts&gt;"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("html missing %q:\n%s", want, body)
+		}
+	}
+	cid := strings.Trim(related[1].header.Get("Content-ID"), "<>")
+	if related[1].ct != "image/png" || !strings.Contains(body, `src="cid:`+cid+`"`) {
+		t.Errorf("image part %s with Content-ID %s not referenced from html", related[1].ct, cid)
+	}
+	if img, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(related[1].body, "\r\n", "")); err != nil || !bytes.Equal(img, png) {
+		t.Errorf("image part does not decode to the chart: %v", err)
+	}
+
+	// A rerun, such as to resume another platform, does not send it again
+	// unless forced.
+	if res, err := et.postThread(ctx, key, posts); err != nil || res[0].ids[0] != strings.Trim(msg.Header.Get("Message-ID"), "<>") {
+		t.Fatalf("rerun got %v, %v, want the journaled Message-ID", res, err)
+	}
+	if n := len(msgs()); n != 1 {
+		t.Fatalf("got %d messages after rerun, want 1", n)
+	}
+	et.force = true
+	if _, err := et.postThread(ctx, key, posts); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(msgs()); n != 2 {
+		t.Fatalf("got %d messages after forced rerun, want 2", n)
+	}
+
+	// Addresses that do not parse are not sent to.
+	bad := *et
+	bad.to = []string{"staff@"}
+	if _, err := bad.postThread(ctx, threadKey{kind: "weekly", period: "20230730"}, []post{{text: "bad"}}); err == nil {
+		t.Error("got no error for a bad address")
+	}
+	if n := len(msgs()); n != 2 {
+		t.Fatalf("got %d messages after a bad address, want 2", n)
+	}
+
+	// The server offers no STARTTLS, so requiring it fails.
+	et.tls = "starttls"
+	if _, err := et.postThread(ctx, threadKey{kind: "weekly", period: "20230723"}, []post{{text: "next"}}); err == nil {
+		t.Error("got no error without STARTTLS support")
+	}
+}
+
+type mimePart struct {
+	header textproto.MIMEHeader
+	ct     string
+	body   string
+}
+
+func readMultipart(t *testing.T, contentType string, r io.Reader, want string) []mimePart {
+	t.Helper()
+
+	mt, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mt != want {
+		t.Fatalf("content type = %s, want %s", mt, want)
+	}
+
+	var parts []mimePart
+	mr := multipart.NewReader(r, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			return parts
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ct, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type"))
+		parts = append(parts, mimePart{header: p.Header, ct: ct, body: string(b)})
+	}
+}
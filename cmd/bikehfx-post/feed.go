@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"image"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/graxinc/errutil"
+)
+
+// feedThreader writes each thread as an entry in an Atom feed, feed.xml, and
+// a JSON Feed, feed.json, in dir, keeping the latest maxEntries. Images go in
+// dir/images. The JSON Feed is also what later runs read entries back from.
+type feedThreader struct {
+	dir        string
+	baseURL    string // where dir is served, for absolute links; required for images
+	title      string
+	maxEntries int
+
+	mu sync.Mutex
+}
+
+const (
+	feedAtomFile = "feed.xml"
+	feedJSONFile = "feed.json"
+	feedImageDir = "images"
+)
+
+type jsonFeed struct {
+	Version string         `json:"version"`
+	Title   string         `json:"title"`
+	FeedURL string         `json:"feed_url,omitempty"`
+	Items   []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string    `json:"id"`
+	Title         string    `json:"title"`
+	ContentHTML   string    `json:"content_html"`
+	ContentText   string    `json:"content_text"`
+	Image         string    `json:"image,omitempty"`
+	DatePublished time.Time `json:"date_published"`
+	DateModified  time.Time `json:"date_modified"`
+}
+
+func (f *feedThreader) postThread(ctx context.Context, key threadKey, posts []post) ([]threadResult, error) {
+	id, err := f.write(key, posts)
+	if err != nil {
+		err = errutil.Witht(err, errutil.Tags{"platform": "feed"})
+		return []threadResult{{platform: "feed", err: err}}, err
+	}
+	return []threadResult{{platform: "feed", ids: []string{id}}}, nil
+}
+
+// write adds or replaces the entry for key and returns its ID.
+func (f *feedThreader) write(key threadKey, posts []post) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(posts) == 0 {
+		return "", nil
+	}
+	// Feed readers cannot resolve relative image links.
+	if f.baseURL == "" && slices.ContainsFunc(posts, func(p post) bool { return len(p.media) > 0 }) {
+		return "", errutil.New(errutil.Tags{"msg": "feed base URL is required for images"})
+	}
+
+	feed, err := f.read()
+	if err != nil {
+		return "", errutil.With(err)
+	}
+
+	slug := key.kind + "-" + key.period
+	now := time.Now().UTC().Truncate(time.Second)
+	item := jsonFeedItem{
+		ID:            "urn:bikehfx:" + slug,
+		DatePublished: now,
+		DateModified:  now,
+	}
+	if i := slices.IndexFunc(feed.Items, func(it jsonFeedItem) bool { return it.ID == item.ID }); i >= 0 {
+		item.DatePublished = feed.Items[i].DatePublished
+		feed.Items = slices.Delete(feed.Items, i, i+1)
+		if err := f.removeImages(slug); err != nil {
+			return "", errutil.With(err)
+		}
+	}
+
+	item.Title = key.title()
+
+	var htmlB, textB strings.Builder
+	for pi, p := range posts {
+		htmlB.WriteString("<p>" + strings.ReplaceAll(html.EscapeString(p.text), "\n", "<br>\n") + "</p>\n")
+		if pi > 0 {
+			textB.WriteString("\n\n")
+		}
+		textB.WriteString(p.text)
+
+		for mi, m := range p.media {
+			_, format, err := image.DecodeConfig(bytes.NewReader(m.b))
+			if err != nil {
+				return "", errutil.With(err)
+			}
+			name := fmt.Sprintf("%s-%02d-%02d.%s", slug, pi, mi, format)
+			if err := writePublicFile(filepath.Join(f.dir, feedImageDir, name), m.b); err != nil {
+				return "", errutil.With(err)
+			}
+			src := f.baseURL + feedImageDir + "/" + name
+			if item.Image == "" {
+				item.Image = src
+			}
+			fmt.Fprintf(&htmlB, "<p><img src=\"%s\" alt=\"%s\"></p>\n", html.EscapeString(src), html.EscapeString(m.altText))
+		}
+	}
+	item.ContentHTML = htmlB.String()
+	item.ContentText = textB.String()
+
+	// Newest first, dropping the oldest past the cap.
+	feed.Items = append([]jsonFeedItem{item}, feed.Items...)
+	slices.SortStableFunc(feed.Items, func(a, b jsonFeedItem) int { return b.DatePublished.Compare(a.DatePublished) })
+	if f.maxEntries > 0 && len(feed.Items) > f.maxEntries {
+		for _, old := range feed.Items[f.maxEntries:] {
+			if err := f.removeImages(strings.TrimPrefix(old.ID, "urn:bikehfx:")); err != nil {
+				return "", errutil.With(err)
+			}
+		}
+		feed.Items = feed.Items[:f.maxEntries]
+	}
+
+	if err := f.writeJSON(feed); err != nil {
+		return "", errutil.With(err)
+	}
+	if err := f.writeAtom(feed); err != nil {
+		return "", errutil.With(err)
+	}
+	return item.ID, nil
+}
+
+func (f *feedThreader) read() (jsonFeed, error) {
+	b, err := os.ReadFile(filepath.Join(f.dir, feedJSONFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return jsonFeed{}, nil
+	}
+	if err != nil {
+		return jsonFeed{}, errutil.With(err)
+	}
+
+	var feed jsonFeed
+	if err := json.Unmarshal(b, &feed); err != nil {
+		return jsonFeed{}, errutil.With(err)
+	}
+	return feed, nil
+}
+
+func (f *feedThreader) removeImages(slug string) error {
+	paths, err := filepath.Glob(filepath.Join(f.dir, feedImageDir, slug+"-[0-9][0-9]-[0-9][0-9].*"))
+	if err != nil {
+		return errutil.With(err)
+	}
+	for _, p := range paths {
+		if err := os.Remove(p); err != nil {
+			return errutil.With(err)
+		}
+	}
+	return nil
+}
+
+func (f *feedThreader) writeJSON(feed jsonFeed) error {
+	feed.Version = "https://jsonfeed.org/version/1.1"
+	feed.Title = f.title
+	feed.FeedURL = ""
+	if f.baseURL != "" {
+		feed.FeedURL = f.baseURL + feedJSONFile
+	}
+
+	b, err := json.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return errutil.With(err)
+	}
+	return writePublicFile(filepath.Join(f.dir, feedJSONFile), b)
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	Title     string      `xml:"title"`
+	ID        string      `xml:"id"`
+	Published string      `xml:"published"`
+	Updated   string      `xml:"updated"`
+	Content   atomContent `xml:"content"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+func (f *feedThreader) writeAtom(feed jsonFeed) error {
+	af := atomFeed{
+		Title:  f.title,
+		ID:     "urn:bikehfx:feed",
+		Author: atomAuthor{Name: f.title},
+	}
+	if f.baseURL != "" {
+		af.Links = append(af.Links, atomLink{Rel: "self", Href: f.baseURL + feedAtomFile})
+	}
+
+	var updated time.Time
+	for _, it := range feed.Items {
+		af.Entries = append(af.Entries, atomEntry{
+			Title:     it.Title,
+			ID:        it.ID,
+			Published: it.DatePublished.Format(time.RFC3339),
+			Updated:   it.DateModified.Format(time.RFC3339),
+			Content:   atomContent{Type: "html", Body: it.ContentHTML},
+		})
+		if it.DateModified.After(updated) {
+			updated = it.DateModified
+		}
+	}
+	af.Updated = updated.Format(time.RFC3339)
+
+	b, err := xml.MarshalIndent(af, "", "  ")
+	if err != nil {
+		return errutil.With(err)
+	}
+	return writePublicFile(filepath.Join(f.dir, feedAtomFile), append([]byte(xml.Header), b...))
+}
+
+// writePublicFile writes path atomically, readable by all so a web server
+// can serve it.
+func writePublicFile(path string, b []byte) error {
+	if err := writeFileAtomic(path, b); err != nil {
+		return errutil.With(err)
+	}
+	return os.Chmod(path, 0o644)
+}
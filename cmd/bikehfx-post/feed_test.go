@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFeedThreader(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	ft := &feedThreader{dir: dir, baseURL: "https://hfx.bike/feed/", title: "BikeHfx", maxEntries: 2}
+	png := testPNG(t, 40, 20, false)
+
+	thread := func(period, text string) []post {
+		return []post{
+			{text: text + "\n\n123 Apple", media: []postMedia{{b: png, altText: `Counts "by hour" <chart>`}}},
+			{text: "second"},
+		}
+	}
+
+	for _, period := range []string{"20230719", "20230720", "20230721"} {
+		results, err := ft.postThread(ctx, threadKey{kind: "daily", period: period}, thread(period, "579 bikes on "+period))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "urn:bikehfx:daily-" + period; len(results) != 1 || len(results[0].ids) != 1 || results[0].ids[0] != want {
+			t.Fatalf("results = %v, want one entry %s", results, want)
+		}
+	}
+
+	// Reposting replaces the entry rather than adding another.
+	if _, err := ft.postThread(ctx, threadKey{kind: "daily", period: "20230721"}, thread("20230721", "580 bikes on 20230721")); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, "feed.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var feed jsonFeed
+	if err := json.Unmarshal(b, &feed); err != nil {
+		t.Fatal(err)
+	}
+	if feed.Version != "https://jsonfeed.org/version/1.1" || feed.FeedURL != "https://hfx.bike/feed/feed.json" {
+		t.Errorf("feed = %+v", feed)
+	}
+	var titles []string
+	for _, it := range feed.Items {
+		titles = append(titles, it.Title)
+	}
+	if got, want := strings.Join(titles, "|"), "Day review: Fri Jul 21, 2023|Day review: Thu Jul 20, 2023"; got != want {
+		t.Errorf("titles = %q, want %q", got, want)
+	}
+
+	item := feed.Items[0]
+	if item.Image != "https://hfx.bike/feed/images/daily-20230721-00-00.png" {
+		t.Errorf("image = %q", item.Image)
+	}
+	if !strings.Contains(item.ContentHTML, `alt="Counts &#34;by hour&#34; &lt;chart&gt;"`) || !strings.Contains(item.ContentHTML, "580 bikes on 20230721<br>\n<br>\n123 Apple") {
+		t.Errorf("content_html = %s", item.ContentHTML)
+	}
+	if item.ContentText != "580 bikes on 20230721\n\n123 Apple\n\nsecond" {
+		t.Errorf("content_text = %q", item.ContentText)
+	}
+
+	// Images of dropped entries are removed.
+	images, err := filepath.Glob(filepath.Join(dir, "images", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(images) != 2 || filepath.Base(images[0]) != "daily-20230720-00-00.png" || filepath.Base(images[1]) != "daily-20230721-00-00.png" {
+		t.Errorf("images = %v, want the two kept entries' images", images)
+	}
+
+	b, err = os.ReadFile(filepath.Join(dir, "feed.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var af atomFeed
+	if err := xml.Unmarshal(b, &af); err != nil {
+		t.Fatal(err)
+	}
+	if len(af.Entries) != 2 || af.Entries[0].ID != "urn:bikehfx:daily-20230721" || af.Entries[0].Content.Type != "html" || af.Entries[0].Content.Body != item.ContentHTML {
+		t.Errorf("atom entries = %+v", af.Entries)
+	}
+	if len(af.Links) != 1 || af.Links[0].Href != "https://hfx.bike/feed/feed.xml" {
+		t.Errorf("atom links = %+v", af.Links)
+	}
+
+	// Without a base URL, images could not be linked.
+	noBase := &feedThreader{dir: t.TempDir(), title: "BikeHfx"}
+	if _, err := noBase.postThread(ctx, threadKey{kind: "daily", period: "20230721"}, thread("20230721", "580 bikes")); err == nil {
+		t.Error("got no error writing images without a base URL")
+	}
+
+	fi, err := os.Stat(filepath.Join(dir, "feed.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0o644 {
+		t.Errorf("feed.xml mode = %v, want 0644", fi.Mode().Perm())
+	}
+}
+
+func TestFeedThreaderWithPosters(t *testing.T) {
+	t.Parallel()
+
+	fp := &fakePoster{}
+	mpt := multiPosterThreader{
+		posterThreader{p: fp, name: "fake"},
+		&feedThreader{dir: t.TempDir(), title: "BikeHfx"},
+	}
+	results, err := mpt.postThread(context.Background(), threadKey{kind: "weekly", period: "20230717"}, []post{{text: "week"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].platform != "fake" || results[1].platform != "feed" || results[1].ids[0] != "urn:bikehfx:weekly-20230717" {
+		t.Errorf("results = %v, want fake then feed", results)
+	}
+}
@@ -19,6 +19,12 @@ type heatmapInput struct {
 	AxisFont       float64               `json:"axis_font,omitempty"`
 	AnnotationFont float64               `json:"annotation_font,omitempty"`
 	Counters       []heatmapInputCounter `json:"counters"`
+
+	// WeatherRow is drawn below the counters with its own color scale.
+	WeatherRow *heatmapInputWeatherRow `json:"weather_row,omitempty"`
+
+	// DarkXValues are shaded as night.
+	DarkXValues []string `json:"dark_x_values,omitempty"`
 }
 
 type heatmapInputCounter struct {
@@ -36,3 +42,15 @@ type heatmapInputTick struct {
 	Position int    `json:"position"`
 	Label    string `json:"label"`
 }
+
+type heatmapInputWeatherRow struct {
+	Name      string                     `json:"name"`
+	ColorMap  string                     `json:"color_map"`
+	Precision int                        `json:"precision"`
+	Values    []heatmapInputWeatherValue `json:"values"`
+}
+
+type heatmapInputWeatherValue struct {
+	X     string  `json:"x"`
+	Value float64 `json:"value"`
+}
@@ -46,6 +46,8 @@ func main() {
 	monthlyCmd := newMonthlyCmd(rootCfg)
 	yearlyCmd := newYearlyCmd(rootCfg)
 	siteCmd := newSiteCmd(rootCfg)
+	recordsCmd := newRecordsCmd(rootCfg)
+	modelCmd := newModelCmd(rootCfg)
 
 	rootCmd.Subcommands = append(rootCmd.Subcommands,
 		dailyCmd,
@@ -53,6 +55,8 @@ func main() {
 		monthlyCmd,
 		yearlyCmd,
 		siteCmd,
+		recordsCmd,
+		modelCmd,
 	)
 
 	if err := rootCmd.Parse(os.Args[1:]); err != nil {
@@ -70,18 +74,51 @@ func main() {
 		URL: rootCfg.queryURL,
 	}
 
+	rootCfg.qu = qu
 	rootCfg.trq = counterbaseTimeRangeQuerier{rootCfg.ccd, qu}
 
+	var weatherCacheDir string
+	if rootCfg.stateDir != "" {
+		weatherCacheDir = filepath.Join(rootCfg.stateDir, "weather")
+	}
+	wr, err := newWeatherer(rootCfg.weather, weatherCacheDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	rootCfg.wr = wr
+	rootCfg.hw = newHourlyWeatherer(rootCfg.weather, wr, weatherCacheDir)
+
+	cm, err := loadCountModel(modelBytes)
+	if err != nil {
+		log.Fatal(err)
+	}
+	rootCfg.cm = cm
+
+	if rootCfg.stateDir != "" {
+		ri, err := loadRecordIndex(filepath.Join(rootCfg.stateDir, "records.json"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		rootCfg.ri = ri
+		rootCfg.rj = &recordJournal{path: filepath.Join(rootCfg.stateDir, "records-journal.jsonl")}
+	}
+
 	rootCfg.rc = counterbaseRecordser{
 		qu:  qu,
 		ccd: rootCfg.ccd,
+		idx: rootCfg.ri,
 	}
 
-	if selectedSubcommand(rootCmd, os.Args[1:]) != siteCmd.Name {
+	if sub := selectedSubcommand(rootCmd, os.Args[1:]); sub != siteCmd.Name && sub != recordsCmd.Name && sub != modelCmd.Name {
 		var tp threadPoster
 		if rootCfg.testMode {
-			tp = posterThreader{p: &savePoster{}, initial: rootCfg.initialPost}
+			tp = posterThreader{p: &savePoster{}, name: "test", initial: rootCfg.initialPost}
 		} else {
+			var pj *postJournal
+			if rootCfg.stateDir != "" {
+				pj = &postJournal{path: filepath.Join(rootCfg.stateDir, "posts.json")}
+			}
+
 			var mtt multiPosterThreader
 
 			if rootCfg.mastodonClientID != "" {
@@ -89,16 +126,85 @@ func main() {
 				if err != nil {
 					log.Println(err)
 				} else {
-					mtt = append(mtt, posterThreader{p: mt, inReplyTo: rootCfg.mastodonInReplyTo, initial: rootCfg.initialPost})
+					mtt = append(mtt, posterThreader{p: mt, name: "mastodon", inReplyTo: rootCfg.mastodonInReplyTo, initial: rootCfg.initialPost, journal: pj, force: rootCfg.force, timeout: rootCfg.postTimeout, chainMaxGap: rootCfg.chainMaxGap, meta: postMetaConfig{
+						visibility: rootCfg.mastodonVisibility,
+						spoiler:    rootCfg.mastodonSpoiler,
+						schedule:   rootCfg.mastodonSchedule,
+						language:   rootCfg.postLanguage,
+					}})
 				}
 			}
 
 			if rootCfg.bskyHandle != "" {
-				bt, err := newBlueskyPoster(rootCfg.bskyServer, rootCfg.bskyHandle, rootCfg.bskyPassword)
+				var sessionPath string
+				if rootCfg.stateDir != "" {
+					sessionPath = filepath.Join(rootCfg.stateDir, "bluesky-session.json")
+				}
+				bt, err := newBlueskyPoster(rootCfg.bskyServer, rootCfg.bskyHandle, rootCfg.bskyPassword, sessionPath)
+				if err != nil {
+					log.Println(err)
+				} else {
+					mtt = append(mtt, posterThreader{p: bt, name: "bluesky", inReplyTo: rootCfg.bskyInReplyTo, initial: rootCfg.initialPost, journal: pj, force: rootCfg.force, timeout: rootCfg.postTimeout, chainMaxGap: rootCfg.chainMaxGap, meta: postMetaConfig{language: rootCfg.postLanguage}})
+				}
+			}
+
+			if rootCfg.matrixRoomID != "" {
+				mp, err := newMatrixPoster(rootCfg.matrixHomeserver, rootCfg.matrixRoomID, rootCfg.matrixAccessToken)
 				if err != nil {
 					log.Println(err)
 				} else {
-					mtt = append(mtt, posterThreader{p: bt, inReplyTo: rootCfg.bskyInReplyTo, initial: rootCfg.initialPost})
+					mtt = append(mtt, posterThreader{p: mp, name: "matrix", inReplyTo: rootCfg.matrixInReplyTo, initial: rootCfg.initialPost, journal: pj, force: rootCfg.force, timeout: rootCfg.postTimeout, chainMaxGap: rootCfg.chainMaxGap})
+				}
+			}
+
+			if rootCfg.webhookURL != "" {
+				wp, err := newWebhookPoster(rootCfg.webhookURL, rootCfg.webhookFormat)
+				if err != nil {
+					log.Println(err)
+				} else {
+					mtt = append(mtt, posterThreader{p: wp, name: "webhook", initial: rootCfg.initialPost, journal: pj, force: rootCfg.force, timeout: rootCfg.postTimeout})
+				}
+			}
+
+			if rootCfg.nostrKey != "" {
+				np, err := newNostrPoster(rootCfg.nostrKey, rootCfg.nostrRelays.vals, rootCfg.nostrUploadURL)
+				if err != nil {
+					log.Println(err)
+				} else {
+					mtt = append(mtt, posterThreader{p: np, name: "nostr", inReplyTo: rootCfg.nostrInReplyTo, initial: rootCfg.initialPost, journal: pj, force: rootCfg.force, timeout: rootCfg.postTimeout, chainMaxGap: rootCfg.chainMaxGap, meta: postMetaConfig{language: rootCfg.postLanguage}})
+				}
+			}
+
+			if rootCfg.feedDir != "" {
+				baseURL := rootCfg.feedURL
+				if baseURL == "" {
+					log.Println("feed-url is required to write feeds, for links to images")
+				} else {
+					if !strings.HasSuffix(baseURL, "/") {
+						baseURL += "/"
+					}
+					mtt = append(mtt, &feedThreader{dir: rootCfg.feedDir, baseURL: baseURL, title: rootCfg.feedTitle, maxEntries: rootCfg.feedMaxEntries})
+				}
+			}
+
+			if rootCfg.smtpAddr != "" {
+				if err := validateEmailTLS(rootCfg.smtpTLS); err != nil {
+					log.Println(err)
+				} else if rootCfg.smtpFrom == "" || len(rootCfg.smtpTo.vals) == 0 {
+					log.Println("smtp-from and smtp-to are required to send email")
+				} else {
+					mtt = append(mtt, &emailThreader{
+						addr:     rootCfg.smtpAddr,
+						tls:      rootCfg.smtpTLS,
+						username: rootCfg.smtpUsername,
+						password: rootCfg.smtpPassword,
+						from:     rootCfg.smtpFrom,
+						to:       rootCfg.smtpTo.vals,
+						kinds:    rootCfg.smtpKinds.vals,
+						timeout:  rootCfg.postTimeout,
+						journal:  pj,
+						force:    rootCfg.force,
+					})
 				}
 			}
 
@@ -137,11 +243,46 @@ type rootConfig struct {
 
 	initialPost string
 
+	stateDir string
+
+	weather weatherConfig
+
 	mastodonServer       string
 	mastodonClientID     string
 	mastodonClientSecret string
 	mastodonAccessToken  string
 	mastodonInReplyTo    string
+	mastodonVisibility   kindValues
+	mastodonSpoiler      kindValues
+	mastodonSchedule     kindValues
+
+	matrixHomeserver  string
+	matrixRoomID      string
+	matrixAccessToken string
+	matrixInReplyTo   string
+
+	webhookURL    string
+	webhookFormat string
+
+	nostrKey       string
+	nostrRelays    commaSeparatedString
+	nostrUploadURL string
+	nostrInReplyTo string
+
+	feedDir        string
+	feedURL        string
+	feedTitle      string
+	feedMaxEntries int
+
+	smtpAddr     string
+	smtpTLS      string
+	smtpUsername string
+	smtpPassword string
+	smtpFrom     string
+	smtpTo       commaSeparatedString
+	smtpKinds    commaSeparatedString
+
+	postLanguage string
 
 	bskyServer    string
 	bskyHandle    string
@@ -149,10 +290,20 @@ type rootConfig struct {
 	bskyInReplyTo string
 
 	testMode bool
+	force    bool
+
+	postTimeout time.Duration
+	chainMaxGap int
 
 	ccd cyclingCounterDirectory
+	qu  Querier
 	trq counterbaseTimeRangeQuerier
+	wr  weatherer
+	hw  hourlyWeatherer
 	rc  recordser
+	ri  *recordIndex
+	rj  *recordJournal
+	cm  *countModel
 	tp  threadPoster
 }
 
@@ -166,19 +317,60 @@ func newRootCmd() (*ffcli.Command, *rootConfig) {
 
 	fs.StringVar(&cfg.initialPost, "initial-post", "", "if set, text for first post")
 
+	fs.StringVar(&cfg.stateDir, "state-dir", "", "if set, directory for local state such as the record index, journals and weather cache")
+
+	cfg.weather.registerFlags(fs)
+
 	fs.StringVar(&cfg.mastodonServer, "mastodon-server", "", "mastodon server URL")
 	// https://docs.joinmastodon.org/client/token/, requires read:accounts, write:media, write:statuses
 	fs.StringVar(&cfg.mastodonClientID, "mastodon-client-id", "", "mastodon client id/key")
 	fs.StringVar(&cfg.mastodonClientSecret, "mastodon-client-secret", "", "mastodon client secret")
 	fs.StringVar(&cfg.mastodonAccessToken, "mastodon-access-token", "", "mastodon access token")
-	fs.StringVar(&cfg.mastodonInReplyTo, "mastodon-in-reply-to", "", "if set, first post will reply to this status id")
+	fs.StringVar(&cfg.mastodonInReplyTo, "mastodon-in-reply-to", "", "if set, first post will reply to this status id, or auto to reply to the previous period's thread")
+	cfg.mastodonVisibility = kindValues{def: "unlisted", vals: map[string]string{"weekly": "public"}, validate: validateMastodonVisibility}
+	fs.Var(&cfg.mastodonVisibility, "mastodon-visibility", "mastodon visibility (public, unlisted, private or direct), as a default and per kind, repeatable like -mastodon-visibility unlisted -mastodon-visibility weekly=public")
+	fs.Var(&cfg.mastodonSpoiler, "mastodon-spoiler", "if set, mastodon content warning, as a default and per kind, repeatable like -mastodon-spoiler 'yearly=Year in review, with charts'")
+	cfg.mastodonSchedule.validate = validateScheduleTime
+	fs.Var(&cfg.mastodonSchedule, "mastodon-schedule", "if set, RFC 3339 time to schedule mastodon posts for, per kind and repeatable like yearly=2026-01-01T09:00:00-04:00; only single-post threads can be scheduled")
 
 	fs.StringVar(&cfg.bskyServer, "bsky-server", "https://bsky.social", "bluesky server URL")
 	fs.StringVar(&cfg.bskyHandle, "bsky-handle", "", "bluesky handle")
 	fs.StringVar(&cfg.bskyPassword, "bsky-password", "", "bluesky password")
-	fs.StringVar(&cfg.bskyInReplyTo, "bsky-in-reply-to", "", "if set, first post will reply to this status at proto URI or web URL")
+	fs.StringVar(&cfg.bskyInReplyTo, "bsky-in-reply-to", "", "if set, first post will reply to this status at proto URI or web URL, or auto to reply to the previous period's thread")
+
+	fs.StringVar(&cfg.matrixHomeserver, "matrix-homeserver", "", "matrix homeserver URL")
+	fs.StringVar(&cfg.matrixRoomID, "matrix-room-id", "", "matrix room ID to post to, like !abc:example.org")
+	fs.StringVar(&cfg.matrixAccessToken, "matrix-access-token", "", "matrix access token for a user in the room")
+	fs.StringVar(&cfg.matrixInReplyTo, "matrix-in-reply-to", "", "if set, first post will reply to this event ID, or auto to reply to the previous period's thread")
+
+	fs.StringVar(&cfg.webhookURL, "webhook-url", "", "if set, webhook URL to send posts to")
+	fs.StringVar(&cfg.webhookFormat, "webhook-format", "discord", "webhook payload format (discord, or slack which gets text only with alt text in place of images)")
+
+	fs.StringVar(&cfg.nostrKey, "nostr-key", "", "if set, nostr secret key, in hex or nsec form, to sign notes with")
+	fs.Var(&cfg.nostrRelays, "nostr-relays", "comma separated nostr relay URLs to publish to, like wss://relay.example.org")
+	fs.StringVar(&cfg.nostrUploadURL, "nostr-upload-url", "", "NIP-96 upload URL for nostr images")
+	fs.StringVar(&cfg.nostrInReplyTo, "nostr-in-reply-to", "", "if set, first post will reply to this event ID, or auto to reply to the previous period's thread")
+
+	fs.StringVar(&cfg.feedDir, "feed-dir", "", "if set, directory to write Atom and JSON feeds of posted threads to")
+	fs.StringVar(&cfg.feedURL, "feed-url", "", "URL the feed directory is served at, for absolute links; required with feed-dir")
+	fs.StringVar(&cfg.feedTitle, "feed-title", "BikeHfx", "feed title")
+	fs.IntVar(&cfg.feedMaxEntries, "feed-max-entries", 50, "how many threads the feeds keep")
+
+	fs.StringVar(&cfg.smtpAddr, "smtp-addr", "", "if set, host:port of an SMTP server to email threads through")
+	fs.StringVar(&cfg.smtpTLS, "smtp-tls", "starttls", "SMTP TLS mode (starttls, tls for implicit TLS, or none)")
+	fs.StringVar(&cfg.smtpUsername, "smtp-username", "", "if set, SMTP username to authenticate with")
+	fs.StringVar(&cfg.smtpPassword, "smtp-password", "", "SMTP password")
+	fs.StringVar(&cfg.smtpFrom, "smtp-from", "", "email sender address")
+	fs.Var(&cfg.smtpTo, "smtp-to", "comma separated email recipient addresses")
+	cfg.smtpKinds.vals = []string{"weekly", "monthly"}
+	fs.Var(&cfg.smtpKinds, "smtp-kinds", "comma separated kinds of thread to email")
+
+	fs.StringVar(&cfg.postLanguage, "post-language", "en", "if set, ISO 639 language code to tag posts with")
 
 	fs.BoolVar(&cfg.testMode, "test-mode", false, "if enabled, write generated posts to disk instead of posting")
+	fs.BoolVar(&cfg.force, "force", false, "if enabled, post threads even if the post journal shows them already posted")
+	fs.IntVar(&cfg.chainMaxGap, "chain-max-gap", 2, "with auto in-reply-to, how many periods back the previous thread may be before starting a new chain")
+	fs.DurationVar(&cfg.postTimeout, "post-timeout", 5*time.Minute, "how long posting a thread to each platform may take")
 
 	return &ffcli.Command{
 		ShortUsage: "bikehfx-post [flags] <subcommand>",
@@ -233,7 +425,65 @@ func loadDirectory(src string) (Directory, error) {
 }
 
 type threadPoster interface {
-	postThread(context.Context, []post) ([]string, error)
+	postThread(context.Context, threadKey, []post) ([]threadResult, error)
+}
+
+// postThreadSummary posts with tp and logs how each platform went. Once every
+// platform has the thread, the records it announces are added to rj, if set.
+func postThreadSummary(ctx context.Context, tp threadPoster, rj *recordJournal, key threadKey, posts []post, records []recordJournalEntry) error {
+	results, err := tp.postThread(ctx, key, posts)
+	for _, r := range results {
+		log.Println(key.kind, key.period, r)
+	}
+	if err != nil {
+		return errutil.With(err)
+	}
+	if rj != nil && len(records) > 0 {
+		if err := rj.append(records...); err != nil {
+			return errutil.With(err)
+		}
+	}
+	return nil
+}
+
+// periodThread is the thread for one period of a run.
+type periodThread struct {
+	key     threadKey
+	posts   []post
+	records []recordJournalEntry
+}
+
+// addPeriodThread adds the thread for key built by build, unless threads
+// already has it, as when two dates given are in the same week.
+func addPeriodThread(threads []periodThread, key threadKey, build func() ([]post, []recordJournalEntry, error)) ([]periodThread, error) {
+	if slices.ContainsFunc(threads, func(t periodThread) bool { return t.key == key }) {
+		return threads, nil
+	}
+	posts, records, err := build()
+	if err != nil {
+		return nil, errutil.With(err)
+	}
+	return append(threads, periodThread{key: key, posts: posts, records: records}), nil
+}
+
+// postPeriodThreads posts each period as its own thread, in order, so each is
+// journaled under its own period.
+func postPeriodThreads(ctx context.Context, tp threadPoster, rj *recordJournal, threads []periodThread) error {
+	for _, t := range threads {
+		if err := postThreadSummary(ctx, tp, rj, t.key, t.posts, t.records); err != nil {
+			return errutil.With(err)
+		}
+	}
+	return nil
+}
+
+// postedRecords returns the journal for records announced by posting, or nil
+// in test mode where nothing is posted.
+func (c *rootConfig) postedRecords() *recordJournal {
+	if c.testMode {
+		return nil
+	}
+	return c.rj
 }
 
 type poster interface {
@@ -298,6 +548,60 @@ func (c *commaSeparatedString) String() string {
 	return strings.Join(c.vals, ",")
 }
 
+// kindValues holds a value per thread kind. It is set once per kind, like
+// weekly=public, and with a bare value, like unlisted, for kinds not named.
+// Only the first = separates the kind, so a default containing = is set with
+// a leading =.
+type kindValues struct {
+	def  string
+	vals map[string]string
+
+	// If set, validate checks each value.
+	validate func(string) error
+}
+
+// Set sets the value for one kind, or the default for every other kind if s
+// names no kind, keeping the rest.
+func (k *kindValues) Set(s string) error {
+	kind, v, ok := strings.Cut(s, "=")
+	if !ok {
+		kind, v = "", s
+	}
+	if k.validate != nil && v != "" {
+		if err := k.validate(v); err != nil {
+			return errutil.With(err)
+		}
+	}
+	if kind == "" {
+		k.def = v
+		return nil
+	}
+	k.vals = maps.Clone(k.vals)
+	if k.vals == nil {
+		k.vals = make(map[string]string)
+	}
+	k.vals[kind] = v
+	return nil
+}
+
+func (k *kindValues) String() string {
+	var parts []string
+	if k.def != "" {
+		parts = append(parts, k.def)
+	}
+	for _, kind := range slices.Sorted(maps.Keys(k.vals)) {
+		parts = append(parts, kind+"="+k.vals[kind])
+	}
+	return strings.Join(parts, ",")
+}
+
+func (k kindValues) get(kind string) string {
+	if v, ok := k.vals[kind]; ok {
+		return v
+	}
+	return k.def
+}
+
 type timeRange struct {
 	begin, end time.Time // [begin, end)
 }
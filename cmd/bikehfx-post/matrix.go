@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/graxinc/errutil"
+)
+
+// matrixPoster posts to a Matrix room using the client-server API. Each
+// thread is a Matrix thread under its first post, and a first post that
+// replies to something outside the thread, such as the previous day's post,
+// is a plain reply.
+type matrixPoster struct {
+	client      *http.Client
+	homeserver  string
+	roomID      string
+	accessToken string
+
+	mu    sync.Mutex
+	roots map[string]string // event ID to the root of its thread
+}
+
+// Requires an access token for a user that has joined roomID.
+func newMatrixPoster(homeserver, roomID, accessToken string) (*matrixPoster, error) {
+	m := &matrixPoster{
+		client:      &http.Client{Timeout: time.Minute},
+		homeserver:  strings.TrimSuffix(homeserver, "/"),
+		roomID:      roomID,
+		accessToken: accessToken,
+		roots:       make(map[string]string),
+	}
+
+	var whoami struct {
+		UserID string `json:"user_id"`
+	}
+	if err := m.do(context.Background(), http.MethodGet, "/_matrix/client/v3/account/whoami", "", nil, &whoami); err != nil {
+		return nil, errutil.With(err)
+	}
+	return m, nil
+}
+
+func (m *matrixPoster) post(ctx context.Context, p post) (string, error) {
+	rel, root, err := m.relation(ctx, p.inReplyTo)
+	if err != nil {
+		return "", errutil.With(err)
+	}
+
+	content := map[string]any{
+		"msgtype": "m.text",
+		"body":    p.text,
+	}
+	if rel != nil {
+		content["m.relates_to"] = rel
+	}
+
+	// The homeserver answers a repeated transaction ID with the event it
+	// already sent, so a retried post is not sent twice.
+	txn := p.idempotencyKey
+	if txn == "" {
+		sum := sha256.Sum256(fmt.Appendf(nil, "%s\x00%s", p.inReplyTo, p.text))
+		txn = hex.EncodeToString(sum[:16])
+	}
+	txn = "bikehfx-" + txn
+
+	id, err := m.send(ctx, txn, content)
+	if err != nil {
+		return "", errutil.With(err)
+	}
+	if root == "" {
+		root = id
+	}
+	m.setRoot(id, root)
+
+	// Images follow the text in its thread.
+	parent := id
+	for i, pm := range p.media {
+		am, err := adaptMedia(pm.b, m.mediaLimits())
+		if err != nil {
+			return "", errutil.With(err)
+		}
+		uri, err := m.upload(ctx, am)
+		if err != nil {
+			return "", errutil.With(err)
+		}
+
+		imageID, err := m.send(ctx, fmt.Sprintf("%s-image%d", txn, i+1), map[string]any{
+			"msgtype": "m.image",
+			"body":    pm.altText,
+			"url":     uri,
+			"info": map[string]any{
+				"mimetype": am.mimeType,
+				"size":     len(am.b),
+				"w":        am.width,
+				"h":        am.height,
+			},
+			"m.relates_to": matrixThreadRelation(root, parent),
+		})
+		if err != nil {
+			return "", errutil.With(err)
+		}
+		m.setRoot(imageID, root)
+		parent = imageID
+	}
+
+	return id, nil
+}
+
+// mediaLimits returns the default upload limit of Synapse.
+func (m *matrixPoster) mediaLimits() mediaLimits {
+	return mediaLimits{maxBytes: 50 << 20}
+}
+
+// relation returns the m.relates_to for a post replying to parent, and the
+// root of the thread it joins, if any.
+func (m *matrixPoster) relation(ctx context.Context, parent string) (map[string]any, string, error) {
+	if parent == "" {
+		return nil, "", nil
+	}
+
+	root, ok := m.root(parent)
+	if !ok {
+		// Not posted by this run, such as when resuming a thread. Events in a
+		// thread say so; anything else is replied to from a new thread.
+		var ev struct {
+			Content struct {
+				RelatesTo struct {
+					RelType string `json:"rel_type"`
+					EventID string `json:"event_id"`
+				} `json:"m.relates_to"`
+			} `json:"content"`
+		}
+		if err := m.do(ctx, http.MethodGet, "/_matrix/client/v3/rooms/"+url.PathEscape(m.roomID)+"/event/"+url.PathEscape(parent), "", nil, &ev); err != nil {
+			return nil, "", errutil.With(err)
+		}
+		if ev.Content.RelatesTo.RelType == "m.thread" {
+			root = ev.Content.RelatesTo.EventID
+		}
+	}
+
+	if root == "" {
+		return map[string]any{"m.in_reply_to": map[string]any{"event_id": parent}}, "", nil
+	}
+	return matrixThreadRelation(root, parent), root, nil
+}
+
+// matrixThreadRelation puts an event in root's thread after parent, falling
+// back to a reply to parent for clients without threads.
+func matrixThreadRelation(root, parent string) map[string]any {
+	return map[string]any{
+		"rel_type":        "m.thread",
+		"event_id":        root,
+		"is_falling_back": true,
+		"m.in_reply_to":   map[string]any{"event_id": parent},
+	}
+}
+
+func (m *matrixPoster) root(id string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	root, ok := m.roots[id]
+	return root, ok
+}
+
+func (m *matrixPoster) setRoot(id, root string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.roots[id] = root
+}
+
+func (m *matrixPoster) send(ctx context.Context, txn string, content map[string]any) (string, error) {
+	b, err := json.Marshal(content)
+	if err != nil {
+		return "", errutil.With(err)
+	}
+
+	var out struct {
+		EventID string `json:"event_id"`
+	}
+	path := "/_matrix/client/v3/rooms/" + url.PathEscape(m.roomID) + "/send/m.room.message/" + url.PathEscape(txn)
+	if err := m.do(ctx, http.MethodPut, path, "application/json", bytes.NewReader(b), &out); err != nil {
+		return "", errutil.With(err)
+	}
+	return out.EventID, nil
+}
+
+func (m *matrixPoster) upload(ctx context.Context, am adaptedMedia) (string, error) {
+	var out struct {
+		ContentURI string `json:"content_uri"`
+	}
+	ext := strings.TrimPrefix(am.mimeType, "image/")
+	if err := m.do(ctx, http.MethodPost, "/_matrix/media/v3/upload?filename=image."+ext, am.mimeType, bytes.NewReader(am.b), &out); err != nil {
+		return "", errutil.With(err)
+	}
+	return out.ContentURI, nil
+}
+
+func (m *matrixPoster) do(ctx context.Context, method, path, contentType string, body io.Reader, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, m.homeserver+path, body)
+	if err != nil {
+		return errutil.With(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+m.accessToken)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return errutil.With(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var merr struct {
+			ErrCode string `json:"errcode"`
+			Error   string `json:"error"`
+		}
+		_ = json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&merr)
+		return errutil.New(errutil.Tags{"msg": "matrix request failed", "path": req.URL.Path, "status": resp.StatusCode, "errcode": merr.ErrCode, "error": merr.Error})
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return errutil.With(err)
+	}
+	return nil
+}
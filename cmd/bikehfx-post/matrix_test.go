@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeHomeserver is a stand-in Matrix homeserver for one room.
+type fakeHomeserver struct {
+	mu      sync.Mutex
+	events  map[string]map[string]any
+	order   []string
+	uploads []string
+	txns    map[string]string // transaction ID to event ID
+}
+
+func newFakeHomeserver(t *testing.T) (*fakeHomeserver, *httptest.Server) {
+	hs := &fakeHomeserver{events: make(map[string]map[string]any), txns: make(map[string]string)}
+	srv := httptest.NewServer(hs)
+	t.Cleanup(srv.Close)
+	return hs, srv
+}
+
+func (hs *fakeHomeserver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"errcode":"M_UNKNOWN_TOKEN","error":"Invalid access token"}`)
+		return
+	}
+
+	const room = "/_matrix/client/v3/rooms/!room:hfx.bike/"
+	path := r.URL.Path
+	switch {
+	case path == "/_matrix/client/v3/account/whoami":
+		fmt.Fprint(w, `{"user_id":"@stats:hfx.bike"}`)
+
+	case r.Method == http.MethodPost && path == "/_matrix/media/v3/upload":
+		b, _ := io.ReadAll(r.Body)
+		hs.uploads = append(hs.uploads, r.Header.Get("Content-Type"))
+		fmt.Fprintf(w, `{"content_uri":"mxc://hfx.bike/%d-%d"}`, len(hs.uploads), len(b))
+
+	case r.Method == http.MethodPut && strings.HasPrefix(path, room+"send/m.room.message/"):
+		txn := strings.TrimPrefix(path, room+"send/m.room.message/")
+		if id, ok := hs.txns[txn]; ok {
+			// Homeservers answer a repeated transaction with its event.
+			fmt.Fprintf(w, `{"event_id":%q}`, id)
+			return
+		}
+
+		var content map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&content); err != nil {
+			http.Error(w, `{"errcode":"M_NOT_JSON"}`, http.StatusBadRequest)
+			return
+		}
+		id := fmt.Sprintf("$%d", len(hs.order)+1)
+		hs.events[id] = content
+		hs.order = append(hs.order, id)
+		hs.txns[txn] = id
+		fmt.Fprintf(w, `{"event_id":%q}`, id)
+
+	case r.Method == http.MethodGet && strings.HasPrefix(path, room+"event/"):
+		id := strings.TrimPrefix(path, room+"event/")
+		content, ok := hs.events[id]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"errcode":"M_NOT_FOUND","error":"Event not found"}`)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"event_id": id, "content": content})
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// relation summarizes an event's m.relates_to as "thread root parent",
+// "reply parent" or "".
+func (hs *fakeHomeserver) relation(id string) string {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	rel, _ := hs.events[id]["m.relates_to"].(map[string]any)
+	if rel == nil {
+		return ""
+	}
+	parent, _ := rel["m.in_reply_to"].(map[string]any)
+	if rel["rel_type"] == "m.thread" {
+		return fmt.Sprintf("thread %v %v", rel["event_id"], parent["event_id"])
+	}
+	return fmt.Sprintf("reply %v", parent["event_id"])
+}
+
+func TestMatrixPoster(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	hs, srv := newFakeHomeserver(t)
+
+	if _, err := newMatrixPoster(srv.URL, "!room:hfx.bike", "wrong"); err == nil {
+		t.Fatal("got no error for a bad access token")
+	}
+
+	mp, err := newMatrixPoster(srv.URL+"/", "!room:hfx.bike", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pt := posterThreader{p: mp, name: "matrix"}
+	png := testPNG(t, 40, 20, false)
+	ids, err := pt.postThreadIDs(ctx, threadKey{kind: "daily", period: "20230721"}, []post{
+		{text: "579 #BikeHfx bikes counted", media: []postMedia{{b: png, altText: "chart"}}},
+		{text: "second"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The text, its image in the thread, then the second post replying to the
+	// text.
+	if got, want := strings.Join(ids, ","), "$1,$3"; got != want {
+		t.Fatalf("ids = %s, want %s", got, want)
+	}
+	for id, want := range map[string]string{"$1": "", "$2": "thread $1 $1", "$3": "thread $1 $1"} {
+		if got := hs.relation(id); got != want {
+			t.Errorf("%s relation = %q, want %q", id, got, want)
+		}
+	}
+
+	hs.mu.Lock()
+	img := hs.events["$2"]
+	uploads := hs.uploads
+	hs.mu.Unlock()
+	info, _ := img["info"].(map[string]any)
+	if img["msgtype"] != "m.image" || img["body"] != "chart" || !strings.HasPrefix(img["url"].(string), "mxc://") || info["mimetype"] != "image/png" || info["w"] != 40.0 || info["h"] != 20.0 {
+		t.Errorf("image event = %v", img)
+	}
+	if len(uploads) != 1 || uploads[0] != "image/png" {
+		t.Errorf("uploads = %v, want one image/png", uploads)
+	}
+
+	// A new run resuming the thread finds its root from the homeserver, and a
+	// thread replying to an earlier one's root starts its own.
+	mp2, err := newMatrixPoster(srv.URL, "!room:hfx.bike", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resumed, err := mp2.post(ctx, post{text: "third", inReplyTo: "$3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hs.relation(resumed); got != "thread $1 $3" {
+		t.Errorf("resumed relation = %q, want thread $1 $3", got)
+	}
+	next, err := mp2.post(ctx, post{text: "next day", inReplyTo: "$1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hs.relation(next); got != "reply $1" {
+		t.Errorf("next day relation = %q, want reply $1", got)
+	}
+
+	if _, err := mp2.post(ctx, post{text: "lost", inReplyTo: "$missing"}); err == nil {
+		t.Error("got no error replying to a missing event")
+	}
+}
+
+func TestMatrixPosterRetry(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	hs, srv := newFakeHomeserver(t)
+	mp, err := newMatrixPoster(srv.URL, "!room:hfx.bike", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := threadKey{kind: "daily", period: "20230721"}
+	posts := []post{{text: "579 #BikeHfx bikes counted", media: []postMedia{{b: testPNG(t, 40, 20, false), altText: "chart"}}}}
+
+	// Without a journal, a rerun tries the same posts again.
+	pt := posterThreader{p: mp, name: "matrix"}
+	first, err := pt.postThreadIDs(ctx, key, posts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := pt.postThreadIDs(ctx, key, posts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(first, again) {
+		t.Errorf("retry ids = %v, want %v", again, first)
+	}
+
+	// Changed content is a new post.
+	posts[0].text = "580 #BikeHfx bikes counted"
+	changed, err := pt.postThreadIDs(ctx, key, posts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slices.Equal(first, changed) {
+		t.Errorf("changed content reused ids %v", first)
+	}
+
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	if got := len(hs.order); got != 4 {
+		t.Errorf("sent %d events, want 4", got)
+	}
+}
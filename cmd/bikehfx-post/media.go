@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"maps"
+	"math"
+	"slices"
+
+	"github.com/graxinc/errutil"
+	"golang.org/x/image/draw"
+)
+
+// mediaLimits are what a platform accepts for an uploaded image. Zero fields
+// are unlimited.
+type mediaLimits struct {
+	maxBytes     int
+	maxPixels    int // width × height
+	maxDimension int // longest side
+}
+
+// adaptedMedia is an image ready to upload.
+type adaptedMedia struct {
+	b             []byte
+	mimeType      string
+	width, height int
+}
+
+// minMediaDimension is the smallest side adaptMedia will shrink an image to
+// before giving up.
+const minMediaDimension = 200
+
+// adaptMedia returns b as is when it fits lim, otherwise downscales and
+// re-encodes it until it does. PNG is tried first, then a PNG reduced to 256
+// colors, then JPEG at decreasing quality, shrinking the image a step each
+// time none of those fit.
+func adaptMedia(b []byte, lim mediaLimits) (adaptedMedia, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(b))
+	if err != nil {
+		return adaptedMedia{}, errutil.With(err)
+	}
+	if (format == "png" || format == "jpeg") && lim.fits(len(b), cfg.Width, cfg.Height) {
+		return adaptedMedia{b: b, mimeType: "image/" + format, width: cfg.Width, height: cfg.Height}, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(b))
+	if err != nil {
+		return adaptedMedia{}, errutil.With(err)
+	}
+
+	w, h := lim.scaledSize(cfg.Width, cfg.Height)
+	for {
+		scaled := img
+		if w != cfg.Width || h != cfg.Height {
+			dst := image.NewRGBA(image.Rect(0, 0, w, h))
+			draw.CatmullRom.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Src, nil)
+			scaled = dst
+		}
+
+		for _, enc := range mediaEncodings {
+			out, err := enc.encode(scaled)
+			if err != nil {
+				return adaptedMedia{}, errutil.With(err)
+			}
+			if lim.maxBytes <= 0 || len(out) <= lim.maxBytes {
+				return adaptedMedia{b: out, mimeType: enc.mimeType, width: w, height: h}, nil
+			}
+		}
+
+		if min(w, h) <= minMediaDimension {
+			return adaptedMedia{}, errutil.New(errutil.Tags{"msg": "could not fit image in limits", "max_bytes": lim.maxBytes, "width": cfg.Width, "height": cfg.Height})
+		}
+		w, h = max(1, w*3/4), max(1, h*3/4)
+	}
+}
+
+func (l mediaLimits) fits(size, w, h int) bool {
+	return (l.maxBytes <= 0 || size <= l.maxBytes) &&
+		(l.maxPixels <= 0 || w*h <= l.maxPixels) &&
+		(l.maxDimension <= 0 || max(w, h) <= l.maxDimension)
+}
+
+// scaledSize returns w and h shrunk to fit the pixel limits, keeping the
+// aspect ratio.
+func (l mediaLimits) scaledSize(w, h int) (int, int) {
+	scale := 1.0
+	if l.maxPixels > 0 && w*h > l.maxPixels {
+		scale = math.Sqrt(float64(l.maxPixels) / float64(w*h))
+	}
+	if l.maxDimension > 0 && max(w, h) > l.maxDimension {
+		scale = min(scale, float64(l.maxDimension)/float64(max(w, h)))
+	}
+	if scale == 1 {
+		return w, h
+	}
+	return max(1, int(float64(w)*scale)), max(1, int(float64(h)*scale))
+}
+
+type mediaEncoding struct {
+	mimeType string
+	encode   func(image.Image) ([]byte, error)
+}
+
+var mediaEncodings = []mediaEncoding{
+	{"image/png", encodePNG},
+	{"image/png", func(img image.Image) ([]byte, error) { return encodePNG(quantizeImage(img)) }},
+	{"image/jpeg", jpegEncoder(90)},
+	{"image/jpeg", jpegEncoder(75)},
+}
+
+func encodePNG(img image.Image) ([]byte, error) {
+	var b bytes.Buffer
+	enc := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := enc.Encode(&b, img); err != nil {
+		return nil, errutil.With(err)
+	}
+	return b.Bytes(), nil
+}
+
+// jpegEncoder returns an encoder for quality, flattening any transparency onto
+// white as JPEG has none.
+func jpegEncoder(quality int) func(image.Image) ([]byte, error) {
+	return func(img image.Image) ([]byte, error) {
+		flat := image.NewRGBA(img.Bounds())
+		draw.Draw(flat, flat.Bounds(), image.White, image.Point{}, draw.Src)
+		draw.Draw(flat, flat.Bounds(), img, img.Bounds().Min, draw.Over)
+
+		var b bytes.Buffer
+		if err := jpeg.Encode(&b, flat, &jpeg.Options{Quality: quality}); err != nil {
+			return nil, errutil.With(err)
+		}
+		return b.Bytes(), nil
+	}
+}
+
+// quantizeImage reduces img to at most 256 colors. Charts usually have fewer
+// than that and come through unchanged; otherwise the most common colors, at
+// 5 bits per channel, are kept and the rest dithered.
+func quantizeImage(img image.Image) *image.Paletted {
+	bounds := img.Bounds()
+
+	counts := make(map[color.RGBA]int)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			counts[color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)]++
+		}
+	}
+
+	exact := len(counts) <= 256
+	if !exact {
+		reduced := make(map[color.RGBA]int)
+		for c, n := range counts {
+			reduced[color.RGBA{c.R &^ 7, c.G &^ 7, c.B &^ 7, c.A}] += n
+		}
+		counts = reduced
+	}
+
+	colors := slices.SortedFunc(maps.Keys(counts), func(a, b color.RGBA) int {
+		if c := cmp.Compare(counts[b], counts[a]); c != 0 {
+			return c
+		}
+		return cmp.Compare(rgbaKey(a), rgbaKey(b))
+	})
+	pal := make(color.Palette, 0, 256)
+	for _, c := range colors[:min(len(colors), 256)] {
+		pal = append(pal, c)
+	}
+
+	out := image.NewPaletted(bounds, pal)
+	if exact {
+		draw.Draw(out, bounds, img, bounds.Min, draw.Src)
+	} else {
+		draw.FloydSteinberg.Draw(out, bounds, img, bounds.Min)
+	}
+	return out
+}
+
+func rgbaKey(c color.RGBA) uint32 {
+	return uint32(c.R)<<24 | uint32(c.G)<<16 | uint32(c.B)<<8 | uint32(c.A)
+}
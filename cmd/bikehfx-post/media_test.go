@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand/v2"
+	"testing"
+)
+
+func testPNG(t *testing.T, w, h int, noisy bool) []byte {
+	t.Helper()
+
+	rng := rand.New(rand.NewPCG(1, 2))
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			c := color.RGBA{uint8(x * 255 / w), uint8(y * 255 / h), 128, 255}
+			if noisy {
+				c.B = uint8(rng.IntN(256))
+			} else if x%7 == 0 {
+				c = color.RGBA{0, 0, 0, 255}
+			} else {
+				c = color.RGBA{255, 255, 255, 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestAdaptMedia(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Fits", func(t *testing.T) {
+		t.Parallel()
+
+		b := testPNG(t, 300, 100, false)
+		got, err := adaptMedia(b, mediaLimits{maxBytes: len(b), maxDimension: 300})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got.b, b) || got.mimeType != "image/png" || got.width != 300 || got.height != 100 {
+			t.Errorf("got %s %dx%d, want the image unchanged", got.mimeType, got.width, got.height)
+		}
+	})
+
+	t.Run("Dimension", func(t *testing.T) {
+		t.Parallel()
+
+		got, err := adaptMedia(testPNG(t, 1200, 400, false), mediaLimits{maxDimension: 600})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.mimeType != "image/png" || got.width != 600 || got.height != 200 {
+			t.Errorf("got %s %dx%d, want image/png 600x200", got.mimeType, got.width, got.height)
+		}
+		checkAdapted(t, got)
+	})
+
+	t.Run("Pixels", func(t *testing.T) {
+		t.Parallel()
+
+		got, err := adaptMedia(testPNG(t, 800, 400, false), mediaLimits{maxPixels: 80_000})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.width*got.height > 80_000 || got.width != 2*got.height {
+			t.Errorf("got %dx%d, want at most 80000 pixels at 2:1", got.width, got.height)
+		}
+		checkAdapted(t, got)
+	})
+
+	t.Run("Bytes", func(t *testing.T) {
+		t.Parallel()
+
+		b := testPNG(t, 800, 400, true)
+		got, err := adaptMedia(b, mediaLimits{maxBytes: 100_000})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got.b) > 100_000 {
+			t.Errorf("got %d bytes, want at most 100000", len(got.b))
+		}
+		if ratio := float64(got.width) / float64(got.height); ratio < 1.98 || ratio > 2.02 {
+			t.Errorf("got %dx%d, want about 2:1", got.width, got.height)
+		}
+		checkAdapted(t, got)
+	})
+
+	t.Run("TooSmall", func(t *testing.T) {
+		t.Parallel()
+
+		if _, err := adaptMedia(testPNG(t, 400, 400, true), mediaLimits{maxBytes: 100}); err == nil {
+			t.Error("got no error, want one for an impossible limit")
+		}
+	})
+}
+
+// checkAdapted checks that the image decodes as its MIME type and size.
+func checkAdapted(t *testing.T, am adaptedMedia) {
+	t.Helper()
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(am.b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if "image/"+format != am.mimeType || cfg.Width != am.width || cfg.Height != am.height {
+		t.Errorf("decoded image/%s %dx%d, want %s %dx%d", format, cfg.Width, cfg.Height, am.mimeType, am.width, am.height)
+	}
+}
+
+func TestQuantizeImageExact(t *testing.T) {
+	t.Parallel()
+
+	img, err := png.Decode(bytes.NewReader(testPNG(t, 70, 10, false)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := quantizeImage(img)
+	if len(q.Palette) != 2 {
+		t.Fatalf("got %d colors, want 2", len(q.Palette))
+	}
+	for y := range 10 {
+		for x := range 70 {
+			if color.RGBAModel.Convert(q.At(x, y)) != color.RGBAModel.Convert(img.At(x, y)) {
+				t.Fatalf("pixel %d,%d changed", x, y)
+			}
+		}
+	}
+}
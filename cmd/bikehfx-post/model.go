@@ -0,0 +1,403 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/graxinc/errutil"
+	"github.com/peterbourgon/ff/v3/ffcli"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+)
+
+// modelBytes is the committed model, refit with the model fit subcommand.
+// Until it is fit it is {} and expected counts are left out of posts.
+//
+//go:embed model.json
+var modelBytes []byte
+
+// countModel predicts a day's total for a fixed set of counters from the
+// weekday, season and weather. It is a least squares fit of the log of the
+// total, so coefficients act as multipliers.
+type countModel struct {
+	FitAt        time.Time `json:"fit_at"`
+	Begin        string    `json:"begin"`
+	End          string    `json:"end"`
+	Days         int       `json:"days"`
+	Counters     []string  `json:"counters"`
+	Features     []string  `json:"features"`
+	Coefficients []float64 `json:"coefficients"`
+}
+
+var modelFeatureNames = []string{
+	"intercept",
+	"monday", "tuesday", "wednesday", "thursday", "friday", "saturday",
+	"season_sin", "season_cos",
+	"max_temp", "max_temp_sq",
+	"rain_log", "rain_day",
+	"snow_log",
+}
+
+func modelFeatures(day time.Time, w weather) []float64 {
+	out := make([]float64, len(modelFeatureNames))
+	out[0] = 1
+	if wd := day.Weekday(); wd != time.Sunday {
+		out[int(wd)] = 1
+	}
+	angle := 2 * math.Pi * float64(day.YearDay()) / 365.25
+	out[7] = math.Sin(angle)
+	out[8] = math.Cos(angle)
+	out[9] = w.max
+	out[10] = w.max * w.max
+	out[11] = math.Log1p(w.rain)
+	if w.rain >= wetDayPrecip {
+		out[12] = 1
+	}
+	out[13] = math.Log1p(w.snow)
+	return out
+}
+
+// loadCountModel parses a model, returning nil if it has not been fit. A nil
+// model is disabled: it gives no expectations.
+func loadCountModel(b []byte) (*countModel, error) {
+	var m countModel
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, errutil.With(err)
+	}
+	if len(m.Coefficients) == 0 {
+		return nil, nil
+	}
+	if !slices.Equal(m.Features, modelFeatureNames) {
+		return nil, errutil.New(errutil.Tags{"msg": "model features do not match, refit the model"})
+	}
+	return &m, nil
+}
+
+func (m *countModel) expected(day time.Time, w weather) float64 {
+	var v float64
+	for i, x := range modelFeatures(day, w) {
+		v += m.Coefficients[i] * x
+	}
+	return math.Exp(v)
+}
+
+// dayExpectation is a day's total for the model counters and what the model
+// expected for the weather. It is the zero value when there is no estimate.
+type dayExpectation struct {
+	actual, expected int
+}
+
+// dayExpectation returns the estimate for day, which needs the day's weather,
+// w if haveWeather is set, and every model counter reporting.
+func (m *countModel) dayExpectation(day time.Time, w weather, haveWeather bool, cs []counterSeries) dayExpectation {
+	if m == nil || !haveWeather {
+		return dayExpectation{}
+	}
+
+	var actual int
+	for _, id := range m.Counters {
+		i := slices.IndexFunc(cs, func(c counterSeries) bool { return c.counter.ID == id })
+		if i < 0 || !countsTowardSum(cs[i]) {
+			return dayExpectation{}
+		}
+		actual += trvSum(cs[i].series)
+	}
+
+	return dayExpectation{actual: actual, expected: int(math.Round(m.expected(day, w)))}
+}
+
+// text describes how the actual total compared to the expected one.
+func (e dayExpectation) text() string {
+	if e.expected <= 0 {
+		return ""
+	}
+	pct := int(math.Round(float64(e.actual-e.expected) / float64(e.expected) * 100))
+	switch {
+	case pct > 0:
+		return fmt.Sprintf("%d%% above expected for the weather", pct)
+	case pct < 0:
+		return fmt.Sprintf("%d%% below expected for the weather", -pct)
+	default:
+		return "As expected for the weather"
+	}
+}
+
+type modelObservation struct {
+	day   time.Time
+	w     weather
+	total int
+}
+
+// collectModelObservations returns days in tr where every counter counted
+// something and weather is known.
+func collectModelObservations(ctx context.Context, trq counterbaseTimeRangeQuerier, wr weatherer, counters []string, tr timeRange) ([]modelObservation, error) {
+	days := tr.splitDate(0, 0, 1)
+	totals := make([]int, len(days))
+	complete := make([]bool, len(days))
+	for i := range complete {
+		complete[i] = true
+	}
+
+	for _, id := range counters {
+		trvs, err := trq.timeRangeValues(ctx, id, days)
+		if err != nil {
+			return nil, errutil.With(err)
+		}
+		for i, trv := range trvs {
+			if trv.val <= 0 {
+				complete[i] = false
+			}
+			totals[i] += trv.val
+		}
+	}
+
+	var out []modelObservation
+	for i, d := range days {
+		if !complete[i] {
+			continue
+		}
+		w, err := wr.weather(ctx, d.begin)
+		if err != nil {
+			continue
+		}
+		out = append(out, modelObservation{day: d.begin, w: w, total: totals[i]})
+	}
+	return out, nil
+}
+
+// fitCountModel returns least squares coefficients for the log of each
+// observation's total.
+func fitCountModel(obs []modelObservation) ([]float64, error) {
+	n := len(modelFeatureNames)
+	if len(obs) < n*4 {
+		return nil, errutil.New(errutil.Tags{"msg": "not enough observations to fit", "observations": len(obs)})
+	}
+
+	// Normal equations: (XᵀX)β = Xᵀy.
+	xtx := make([][]float64, n)
+	for i := range xtx {
+		xtx[i] = make([]float64, n+1)
+	}
+	for _, o := range obs {
+		x := modelFeatures(o.day, o.w)
+		y := math.Log(float64(o.total))
+		for i := range n {
+			for j := range n {
+				xtx[i][j] += x[i] * x[j]
+			}
+			xtx[i][n] += x[i] * y
+		}
+	}
+
+	return solveLinear(xtx)
+}
+
+// solveLinear solves the augmented matrix a in place using Gaussian
+// elimination with partial pivoting.
+func solveLinear(a [][]float64) ([]float64, error) {
+	n := len(a)
+	for col := range n {
+		pivot := col
+		for r := col + 1; r < n; r++ {
+			if math.Abs(a[r][col]) > math.Abs(a[pivot][col]) {
+				pivot = r
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-9 {
+			return nil, errutil.New(errutil.Tags{"msg": "singular model", "feature": modelFeatureNames[col]})
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+
+		for r := range n {
+			if r == col {
+				continue
+			}
+			f := a[r][col] / a[col][col]
+			for c := col; c <= n; c++ {
+				a[r][c] -= f * a[col][c]
+			}
+		}
+	}
+
+	out := make([]float64, n)
+	for i := range n {
+		out[i] = a[i][n] / a[i][i]
+	}
+	return out, nil
+}
+
+func newModelCmd(rootConfig *rootConfig) *ffcli.Command {
+	var (
+		fitFS   = flag.NewFlagSet("bikehfx-post model fit", flag.ExitOnError)
+		fitAsOf = fitFS.String("as-of", time.Now().AddDate(0, 0, -1).Format("20060102"), "inclusive latest day to fit on, in YYYYMMDD form")
+		fitDays = fitFS.Int("days", 730, "number of days of history to fit on")
+		fitOut  = fitFS.String("out", "", "file to write the model to, normally cmd/bikehfx-post/model.json (required)")
+	)
+
+	fitCmd := &ffcli.Command{
+		Name:       "fit",
+		ShortUsage: "bikehfx-post model fit -out <file>",
+		ShortHelp:  "fit the expected count model on history",
+		FlagSet:    fitFS,
+		Exec: func(ctx context.Context, args []string) error {
+			if *fitOut == "" {
+				return errutil.New(errutil.Tags{"msg": "-out is required"})
+			}
+
+			loc, err := time.LoadLocation("America/Halifax")
+			if err != nil {
+				return errutil.With(err)
+			}
+
+			asOfDay, err := time.ParseInLocation("20060102", *fitAsOf, loc)
+			if err != nil {
+				return errutil.With(err)
+			}
+			asOfRange := newTimeRangeDate(asOfDay, 0, 0, 1)
+
+			// Fit on the counters reporting now, so daily totals compare like
+			// for like.
+			cs, err := rootConfig.trq.query(ctx, asOfRange)
+			if err != nil {
+				return errutil.With(err)
+			}
+			var counters []string
+			for _, c := range cs {
+				if countsTowardSum(c) {
+					counters = append(counters, c.counter.ID)
+				}
+			}
+			slices.Sort(counters)
+
+			fitRange := timeRange{begin: asOfRange.end.AddDate(0, 0, -*fitDays), end: asOfRange.end}
+			obs, err := collectModelObservations(ctx, rootConfig.trq, rootConfig.wr, counters, fitRange)
+			if err != nil {
+				return errutil.With(err)
+			}
+			coefs, err := fitCountModel(obs)
+			if err != nil {
+				return errutil.With(err)
+			}
+
+			m := countModel{
+				FitAt:        time.Now().UTC().Truncate(time.Second),
+				Begin:        fitRange.begin.Format("2006-01-02"),
+				End:          asOfDay.Format("2006-01-02"),
+				Days:         len(obs),
+				Counters:     counters,
+				Features:     modelFeatureNames,
+				Coefficients: coefs,
+			}
+			b, err := json.MarshalIndent(m, "", "  ")
+			if err != nil {
+				return errutil.With(err)
+			}
+			if err := os.WriteFile(*fitOut, append(b, '\n'), 0o644); err != nil {
+				return errutil.With(err)
+			}
+
+			fmt.Println("fit model on", len(obs), "days for", len(counters), "counters, wrote", *fitOut)
+			return nil
+		},
+	}
+
+	return &ffcli.Command{
+		Name:       "model",
+		ShortUsage: "bikehfx-post model [<subcommand>]",
+		ShortHelp:  "print the expected count model",
+		LongHelp: "The model is embedded from cmd/bikehfx-post/model.json at build time and ships unfit,\n" +
+			"so daily posts leave out expected counts until it is fit. To fit it, run\n\n" +
+			"  bikehfx-post model fit -out cmd/bikehfx-post/model.json\n\n" +
+			"with access to counterbase, commit the result and rebuild.",
+		FlagSet:     flag.NewFlagSet("bikehfx-post model", flag.ExitOnError),
+		Subcommands: []*ffcli.Command{fitCmd},
+		Exec: func(ctx context.Context, args []string) error {
+			m := rootConfig.cm
+			if m == nil {
+				fmt.Println("model has not been fit, see bikehfx-post model -h")
+				return nil
+			}
+
+			fmt.Printf("fit %s on %d days from %s to %s\n", m.FitAt.Format(time.RFC3339), m.Days, m.Begin, m.End)
+			fmt.Printf("counters: %v\n\n", m.Counters)
+
+			tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "FEATURE\tCOEFFICIENT")
+			for i, name := range m.Features {
+				fmt.Fprintf(tw, "%s\t%.4f\n", name, m.Coefficients[i])
+			}
+			return tw.Flush()
+		},
+	}
+}
+
+type modelResidual struct {
+	day time.Time
+	pct float64
+}
+
+func modelResiduals(m *countModel, obs []modelObservation) []modelResidual {
+	out := make([]modelResidual, 0, len(obs))
+	for _, o := range obs {
+		exp := m.expected(o.day, o.w)
+		out = append(out, modelResidual{day: o.day, pct: (float64(o.total) - exp) / exp * 100})
+	}
+	return out
+}
+
+func modelResidualChart(residuals []modelResidual, title string) ([]byte, error) {
+	if err := initGraph(); err != nil {
+		return nil, errutil.With(err)
+	}
+
+	p := plot.New()
+
+	p.Title.Text = title
+	p.Title.Padding = vg.Length(5)
+
+	p.Y.Label.Text = "% vs expected"
+	p.Y.Label.Padding = vg.Length(5)
+	p.X.Tick.Marker = plot.TimeTicks{Format: "Jan 2"}
+
+	pts := make(plotter.XYs, 0, len(residuals))
+	for _, r := range residuals {
+		pts = append(pts, plotter.XY{X: float64(r.day.Unix()), Y: r.pct})
+	}
+
+	zero := plotter.NewFunction(func(float64) float64 { return 0 })
+	zero.Dashes = []vg.Length{vg.Points(4), vg.Points(4)}
+	p.Add(zero)
+
+	line, points, err := plotter.NewLinePoints(pts)
+	if err != nil {
+		return nil, errutil.With(err)
+	}
+	points.Radius = vg.Points(1.5)
+	p.Add(line, points)
+
+	wt, err := p.WriterTo(20*vg.Centimeter, 10*vg.Centimeter, "png")
+	if err != nil {
+		return nil, errutil.With(err)
+	}
+
+	var b bytes.Buffer
+	if _, err := wt.WriteTo(&b); err != nil {
+		return nil, errutil.With(err)
+	}
+
+	if err := padImage(&b); err != nil {
+		return nil, errutil.With(err)
+	}
+
+	return b.Bytes(), nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/danp/counterbase/directory"
+)
+
+func TestFitCountModel(t *testing.T) {
+	t.Parallel()
+
+	want := []float64{7, 0.3, 0.35, 0.35, 0.3, 0.2, -0.1, -0.2, -0.4, 0.08, -0.001, -0.3, -0.2, -0.5}
+	truth := &countModel{Coefficients: want}
+
+	var obs []modelObservation
+	day := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range 730 {
+		d := day.AddDate(0, 0, i)
+		w := weather{max: 10 + 15*math.Sin(float64(i)/58) + float64(i%7)}
+		if i%5 == 0 {
+			w.rain = float64(i%13) + 0.5
+		}
+		if i%17 == 0 {
+			w.snow = float64(i % 4)
+		}
+		obs = append(obs, modelObservation{day: d, w: w, total: int(math.Round(truth.expected(d, w)))})
+	}
+
+	got, err := fitCountModel(obs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range want {
+		if math.Abs(got[i]-want[i]) > 0.01 {
+			t.Errorf("%s = %.4f, want %.4f", modelFeatureNames[i], got[i], want[i])
+		}
+	}
+}
+
+func TestDayExpectation(t *testing.T) {
+	t.Parallel()
+
+	day := time.Date(2023, 7, 21, 0, 0, 0, 0, time.UTC)
+	m := &countModel{Counters: []string{"a", "b"}, Coefficients: make([]float64, len(modelFeatureNames))}
+	m.Coefficients[0] = math.Log(200)
+	w := weather{max: 24, min: 15}
+
+	series := func(id string, val int, status counterDataStatus) counterSeries {
+		return counterSeries{
+			counter: directory.Counter{ID: id},
+			status:  status,
+			series:  []timeRangeValue{{tr: newTimeRangeDate(day, 0, 0, 1), val: val}},
+		}
+	}
+
+	cs := []counterSeries{series("a", 130, counterDataStatusOK), series("b", 94, counterDataStatusOK), series("c", 1000, counterDataStatusOK)}
+	if got, want := m.dayExpectation(day, w, true, cs).text(), "12% above expected for the weather"; got != want {
+		t.Errorf("text() = %q, want %q", got, want)
+	}
+
+	// A high of 0°C is still weather.
+	if got := m.dayExpectation(day, weather{}, true, cs); got.expected != 200 {
+		t.Errorf("dayExpectation() at 0°C = %+v, want an expectation", got)
+	}
+	if got := m.dayExpectation(day, w, false, cs); got != (dayExpectation{}) {
+		t.Errorf("dayExpectation() without weather = %+v, want none", got)
+	}
+
+	cs[1].status = counterDataStatusMissing
+	if got := m.dayExpectation(day, w, true, cs); got != (dayExpectation{}) {
+		t.Errorf("dayExpectation() with missing counter = %+v, want none", got)
+	}
+}
+
+func TestLoadCountModelUnfit(t *testing.T) {
+	t.Parallel()
+
+	for name, b := range map[string][]byte{"embedded": modelBytes, "empty": []byte("{}")} {
+		m, err := loadCountModel(b)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if m != nil {
+			// The committed model has been fit, which is fine too.
+			continue
+		}
+		day := time.Date(2023, 7, 21, 0, 0, 0, 0, time.UTC)
+		cs := []counterSeries{{counter: directory.Counter{ID: "a"}, series: []timeRangeValue{{tr: newTimeRangeDate(day, 0, 0, 1), val: 100}}}}
+		if got := m.dayExpectation(day, weather{max: 20}, true, cs); got != (dayExpectation{}) || got.text() != "" {
+			t.Errorf("%s: unfit model gave %+v, want no expectation", name, got)
+		}
+	}
+
+	if _, err := loadCountModel([]byte(`{"features":["intercept"],"coefficients":[1]}`)); err == nil {
+		t.Error("expected an error for a model fit on other features")
+	}
+}
@@ -20,7 +20,7 @@ func newMonthlyCmd(rootConfig *rootConfig) *ffcli.Command {
 		month  = fs.String("month", time.Now().AddDate(0, -1, 0).Format("200601"), "month to post for, in YYYYMM form")
 		months commaSeparatedString
 	)
-	fs.Var(&months, "months", "comma-separated months to post, in YYYYMM form, preferred over month")
+	fs.Var(&months, "months", "comma-separated months to post, each as its own thread, in YYYYMM form, preferred over month")
 
 	return &ffcli.Command{
 		Name:       "monthly",
@@ -33,39 +33,40 @@ func newMonthlyCmd(rootConfig *rootConfig) *ffcli.Command {
 				months = []string{*month}
 			}
 
-			return monthlyExec(ctx, months, rootConfig.trq, rootConfig.rc, rootConfig.tp)
+			return monthlyExec(ctx, months, rootConfig.trq, rootConfig.wr, rootConfig.rc, rootConfig.tp, rootConfig.postedRecords())
 		},
 	}
 }
 
-func monthlyExec(ctx context.Context, months []string, trq counterbaseTimeRangeQuerier, rc recordser, tp threadPoster) error {
+func monthlyExec(ctx context.Context, months []string, trq counterbaseTimeRangeQuerier, wr weatherer, rc recordser, tp threadPoster, rj *recordJournal) error {
 	loc, err := time.LoadLocation("America/Halifax")
 	if err != nil {
 		return errutil.With(err)
 	}
 
-	var posts []post
+	var threads []periodThread
 	for _, month := range months {
 		montht, err := time.ParseInLocation("200601", month, loc)
 		if err != nil {
 			return errutil.With(err)
 		}
 
-		ps, err := monthPost(ctx, montht, trq, rc)
+		key := threadKey{kind: "monthly", period: montht.Format("200601")}
+		threads, err = addPeriodThread(threads, key, func() ([]post, []recordJournalEntry, error) {
+			return monthPost(ctx, montht, trq, wr, rc)
+		})
 		if err != nil {
 			return errutil.With(err)
 		}
-
-		posts = append(posts, ps...)
 	}
 
-	if _, err := tp.postThread(ctx, posts); err != nil {
+	if err := postPeriodThreads(ctx, tp, rj, threads); err != nil {
 		return errutil.With(err)
 	}
 	return nil
 }
 
-func monthPost(ctx context.Context, montht time.Time, trq counterbaseTimeRangeQuerier, rc recordser) ([]post, error) {
+func monthPost(ctx context.Context, montht time.Time, trq counterbaseTimeRangeQuerier, weatherer weatherer, rc recordser) ([]post, []recordJournalEntry, error) {
 	var posts []post
 
 	monthRange := newTimeRangeDate(time.Date(montht.Year(), montht.Month(), 1, 0, 0, 0, 0, montht.Location()), 0, 1, 0)
@@ -81,7 +82,7 @@ func monthPost(ctx context.Context, montht time.Time, trq counterbaseTimeRangeQu
 	for _, wr := range monthRanges {
 		monthSeries, err := trq.query(ctx, wr)
 		if err != nil {
-			return nil, errutil.With(err)
+			return nil, nil, errutil.With(err)
 		}
 
 		monthsSeries = append(monthsSeries, monthSeries)
@@ -97,12 +98,17 @@ func monthPost(ctx context.Context, montht time.Time, trq counterbaseTimeRangeQu
 			series:  c.series,
 		})
 	}
-	records, err := rc.records(ctx, monthRange.begin, cs, recordWidthMonth)
+	records, broken, err := rc.records(ctx, monthRange.begin, cs, recordWidthMonth)
 	if err != nil {
-		return nil, errutil.With(err)
+		return nil, nil, errutil.With(err)
 	}
 
-	monthPostText := monthPostText(monthRange, monthsSeries[0], records)
+	rangesWeather := make([]weatherSummary, len(monthRanges))
+	for i, r := range monthRanges {
+		rangesWeather[i] = summarizeWeather(ctx, weatherer, r)
+	}
+
+	monthPostText := monthPostText(monthRange, rangesWeather[0], monthsSeries[0], records)
 
 	graphBegin := monthRange.begin.AddDate(0, -7, 0)
 	graphRange := newTimeRangeDate(graphBegin, 0, 8, 0)
@@ -110,7 +116,7 @@ func monthPost(ctx context.Context, montht time.Time, trq counterbaseTimeRangeQu
 
 	graphCountSeries, err := trq.query(ctx, graphMonths...)
 	if err != nil {
-		return nil, errutil.With(err)
+		return nil, nil, errutil.With(err)
 	}
 
 	monthCounts := make(map[time.Time]int)
@@ -127,7 +133,7 @@ func monthPost(ctx context.Context, montht time.Time, trq counterbaseTimeRangeQu
 
 	gr, err := timeRangeBarGraph(graphTRVs, "Total count by month", func(tr timeRange) string { return tr.begin.Format("Jan") })
 	if err != nil {
-		return nil, errutil.With(err)
+		return nil, nil, errutil.With(err)
 	}
 
 	atg := altTextGenerator{
@@ -152,7 +158,7 @@ func monthPost(ctx context.Context, montht time.Time, trq counterbaseTimeRangeQu
 
 	altText, err := atg.text(graphTRVs)
 	if err != nil {
-		return nil, errutil.With(err)
+		return nil, nil, errutil.With(err)
 	}
 
 	posts = append(posts, post{
@@ -179,14 +185,18 @@ func monthPost(ctx context.Context, montht time.Time, trq counterbaseTimeRangeQu
 
 	prevMonthsPostPrinter := message.NewPrinter(language.English)
 	prevMonthsPostText := prevMonthsPostPrinter.Sprintf("Previous year counts for %v:\n\n", monthRange.begin.Format("Jan"))
-	for _, trv := range graph2TRVs {
-		prevMonthsPostText += prevMonthsPostPrinter.Sprintf("%v: %v\n", trv.tr.begin.Format("2006"), trv.val)
+	for i, trv := range graph2TRVs {
+		prevMonthsPostText += prevMonthsPostPrinter.Sprintf("%v: %v", trv.tr.begin.Format("2006"), trv.val)
+		if wt := rangesWeather[i].shortText(); wt != "" {
+			prevMonthsPostText += " · " + wt
+		}
+		prevMonthsPostText += "\n"
 	}
 
 	slices.Reverse(graph2TRVs)
 	gr2, err := timeRangeBarGraph(graph2TRVs, prevMonthsPostPrinter.Sprintf("Total count for month %v by year", monthRange.begin.Format("Jan")), func(tr timeRange) string { return tr.begin.Format("2006") })
 	if err != nil {
-		return nil, errutil.With(err)
+		return nil, nil, errutil.With(err)
 	}
 
 	atg2 := altTextGenerator{
@@ -211,7 +221,7 @@ func monthPost(ctx context.Context, montht time.Time, trq counterbaseTimeRangeQu
 
 	altText2, err := atg2.text(graph2TRVs)
 	if err != nil {
-		return nil, errutil.With(err)
+		return nil, nil, errutil.With(err)
 	}
 
 	posts = append(posts, post{
@@ -221,10 +231,10 @@ func monthPost(ctx context.Context, montht time.Time, trq counterbaseTimeRangeQu
 		},
 	})
 
-	return posts, nil
+	return posts, broken, nil
 }
 
-func monthPostText(monthRange timeRange, cs []counterSeries, records map[string]recordKind) string {
+func monthPostText(monthRange timeRange, ws weatherSummary, cs []counterSeries, records map[string]recordKind) string {
 	var out strings.Builder
 
 	p := message.NewPrinter(language.English)
@@ -244,6 +254,9 @@ func monthPostText(monthRange timeRange, cs []counterSeries, records map[string]
 	}
 
 	p.Fprintf(&out, "Month review:\n\n%v%v #BikeHfx bikes counted in %v\n\n", sum, recordSymbol(records["sum"]), monthRange.begin.Format("Jan"))
+	if t := ws.text(); t != "" {
+		p.Fprintf(&out, "%v\n\n", t)
+	}
 
 	slices.SortFunc(presentIndices, func(i, j int) int {
 		return cmp.Compare(counterName(cs[i].counter), counterName(cs[j].counter))
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMonthlyPostText(t *testing.T) {
+	t.Parallel()
+
+	monthRange := newTimeRangeDate(time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC), 0, 1, 0)
+
+	var banana, apple counterSeries
+	banana.counter.ID, banana.counter.Name = "b", "Banana"
+	banana.series = []timeRangeValue{{tr: monthRange, val: 4567}}
+	apple.counter.ID, apple.counter.Name = "a", "Apple"
+	apple.series = []timeRangeValue{{tr: monthRange, val: 1234}}
+
+	ws := weatherSummary{days: 31, meanMax: 24.2, meanMin: 15.8, rain: 210.4, wetDays: 12}
+	got := monthPostText(monthRange, ws, []counterSeries{banana, apple}, map[string]recordKind{"sum": recordKindYTD})
+	expect(t, "text.txt", got)
+}
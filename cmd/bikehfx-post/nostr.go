@@ -0,0 +1,541 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/gorilla/websocket"
+	"github.com/graxinc/errutil"
+)
+
+// nostrPoster publishes posts as kind 1 text notes signed with key to each of
+// relays. Images are uploaded to a NIP-96 server and linked from the note.
+// Threads use NIP-10 root and reply tags.
+type nostrPoster struct {
+	client    *http.Client
+	dialer    *websocket.Dialer
+	key       *btcec.PrivateKey
+	pubkey    string
+	relays    []string
+	uploadURL string
+
+	mu    sync.Mutex
+	roots map[string]string // event ID to the root of its thread
+}
+
+// nostrEvent is an event as in NIP-01.
+type nostrEvent struct {
+	ID        string     `json:"id"`
+	PubKey    string     `json:"pubkey"`
+	CreatedAt int64      `json:"created_at"`
+	Kind      int        `json:"kind"`
+	Tags      [][]string `json:"tags"`
+	Content   string     `json:"content"`
+	Sig       string     `json:"sig"`
+}
+
+const (
+	nostrKindTextNote = 1
+	nostrKindHTTPAuth = 27235
+)
+
+// key is the secret key in hex or NIP-19 nsec form. uploadURL is a NIP-96
+// upload endpoint, such as the api_url of a server's nip96.json.
+func newNostrPoster(key string, relays []string, uploadURL string) (*nostrPoster, error) {
+	kb, err := decodeNostrKey(key)
+	if err != nil {
+		return nil, errutil.With(err)
+	}
+	if len(relays) == 0 {
+		return nil, errutil.New(errutil.Tags{"msg": "no nostr relays"})
+	}
+
+	priv, pub := btcec.PrivKeyFromBytes(kb)
+	return &nostrPoster{
+		client:    &http.Client{Timeout: time.Minute},
+		dialer:    &websocket.Dialer{HandshakeTimeout: 30 * time.Second},
+		key:       priv,
+		pubkey:    hex.EncodeToString(schnorr.SerializePubKey(pub)),
+		relays:    relays,
+		uploadURL: uploadURL,
+		roots:     make(map[string]string),
+	}, nil
+}
+
+func (n *nostrPoster) post(ctx context.Context, p post) (string, error) {
+	tags, root, err := n.replyTags(ctx, p.inReplyTo)
+	if err != nil {
+		return "", errutil.With(err)
+	}
+
+	content := p.text
+	for _, pm := range p.media {
+		if n.uploadURL == "" {
+			return "", errutil.New(errutil.Tags{"msg": "nostr upload URL required for media"})
+		}
+		am, err := adaptMedia(pm.b, n.mediaLimits())
+		if err != nil {
+			return "", errutil.With(err)
+		}
+		u, err := n.upload(ctx, am, pm.altText)
+		if err != nil {
+			return "", errutil.With(err)
+		}
+
+		// NIP-92 image metadata; clients show the link as the image.
+		content += "\n" + u
+		sum := sha256.Sum256(am.b)
+		imeta := []string{"imeta", "url " + u, "m " + am.mimeType, fmt.Sprintf("dim %dx%d", am.width, am.height), "x " + hex.EncodeToString(sum[:])}
+		if pm.altText != "" {
+			imeta = append(imeta, "alt "+pm.altText)
+		}
+		tags = append(tags, imeta)
+	}
+	for _, word := range facetWords(content) {
+		if t, _, ok := parseTag(word); ok {
+			tags = append(tags, []string{"t", strings.ToLower(t)})
+		}
+	}
+	if lang := p.meta.language; lang != "" {
+		tags = append(tags, []string{"L", "ISO-639-1"}, []string{"l", lang, "ISO-639-1"})
+	}
+
+	ev, err := n.sign(nostrKindTextNote, tags, content)
+	if err != nil {
+		return "", errutil.With(err)
+	}
+	if err := n.publish(ctx, ev); err != nil {
+		return "", errutil.With(err)
+	}
+
+	if root == "" {
+		root = ev.ID
+	}
+	n.setRoot(ev.ID, root)
+	return ev.ID, nil
+}
+
+// mediaLimits keeps uploads within what free NIP-96 servers accept.
+func (n *nostrPoster) mediaLimits() mediaLimits {
+	return mediaLimits{maxBytes: 5 << 20}
+}
+
+// replyTags returns the tags for a note replying to parent, and the root of
+// the thread it joins, if any.
+func (n *nostrPoster) replyTags(ctx context.Context, parent string) ([][]string, string, error) {
+	if parent == "" {
+		return nil, "", nil
+	}
+
+	root, ok := n.root(parent)
+	author := n.pubkey
+	if !ok {
+		// Not posted by this run, such as when resuming a thread, so ask the
+		// relays whether parent is itself in a thread.
+		ev, err := n.fetch(ctx, parent)
+		if err != nil {
+			return nil, "", errutil.With(err)
+		}
+		author = ev.PubKey
+		root = parent
+		for _, t := range ev.Tags {
+			if len(t) >= 4 && t[0] == "e" && t[3] == "root" {
+				root = t[1]
+			}
+		}
+	}
+
+	relay := n.relays[0]
+	tags := [][]string{{"e", root, relay, "root"}}
+	if root != parent {
+		tags = append(tags, []string{"e", parent, relay, "reply"})
+	}
+	if author != n.pubkey {
+		tags = append(tags, []string{"p", author})
+	}
+	return tags, root, nil
+}
+
+func (n *nostrPoster) root(id string) (string, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	root, ok := n.roots[id]
+	return root, ok
+}
+
+func (n *nostrPoster) setRoot(id, root string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.roots[id] = root
+}
+
+// sign returns a signed event created now.
+func (n *nostrPoster) sign(kind int, tags [][]string, content string) (nostrEvent, error) {
+	if tags == nil {
+		tags = [][]string{}
+	}
+	ev := nostrEvent{
+		PubKey:    n.pubkey,
+		CreatedAt: time.Now().Unix(),
+		Kind:      kind,
+		Tags:      tags,
+		Content:   content,
+	}
+
+	id, err := nostrEventID(ev)
+	if err != nil {
+		return nostrEvent{}, errutil.With(err)
+	}
+	sig, err := schnorr.Sign(n.key, id)
+	if err != nil {
+		return nostrEvent{}, errutil.With(err)
+	}
+	ev.ID = hex.EncodeToString(id)
+	ev.Sig = hex.EncodeToString(sig.Serialize())
+	return ev, nil
+}
+
+// nostrEventID returns the hash of ev's serialization as in NIP-01.
+func nostrEventID(ev nostrEvent) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode([]any{0, ev.PubKey, ev.CreatedAt, ev.Kind, ev.Tags, ev.Content}); err != nil {
+		return nil, errutil.With(err)
+	}
+	sum := sha256.Sum256(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+	return sum[:], nil
+}
+
+// publish sends ev to every relay, succeeding if any accept it.
+func (n *nostrPoster) publish(ctx context.Context, ev nostrEvent) error {
+	errs := make([]error, len(n.relays))
+	var wg sync.WaitGroup
+	for i, relay := range n.relays {
+		wg.Go(func() {
+			errs[i] = n.publishTo(ctx, relay, ev)
+		})
+	}
+	wg.Wait()
+
+	var accepted bool
+	for i, err := range errs {
+		if err != nil {
+			fmt.Println("could not publish nostr event", ev.ID, "to", n.relays[i]+":", err)
+			continue
+		}
+		accepted = true
+	}
+	if !accepted {
+		return errutil.New(errutil.Tags{"msg": "no nostr relay accepted event", "id": ev.ID})
+	}
+	return nil
+}
+
+func (n *nostrPoster) publishTo(ctx context.Context, relay string, ev nostrEvent) error {
+	conn, err := n.dial(ctx, relay)
+	if err != nil {
+		return errutil.With(err)
+	}
+	defer conn.Close()
+
+	if err := conn.WriteJSON([]any{"EVENT", ev}); err != nil {
+		return errutil.With(err)
+	}
+	for {
+		msg, err := readRelayMessage(conn)
+		if err != nil {
+			return errutil.With(err)
+		}
+		typ, err := relayMessageType(msg)
+		if err != nil {
+			return errutil.With(err)
+		}
+		// ["OK", <id>, <accepted>, <message>]
+		if typ != "OK" || len(msg) < 4 {
+			continue
+		}
+		var id, reason string
+		var ok bool
+		if json.Unmarshal(msg[1], &id) != nil || id != ev.ID {
+			continue
+		}
+		if err := json.Unmarshal(msg[2], &ok); err != nil {
+			return errutil.With(err)
+		}
+		if !ok {
+			if err := json.Unmarshal(msg[3], &reason); err != nil {
+				return errutil.With(err)
+			}
+			return errutil.New(errutil.Tags{"msg": "nostr relay rejected event", "reason": reason})
+		}
+		return nil
+	}
+}
+
+// fetch returns the event with id from the first relay that has it.
+func (n *nostrPoster) fetch(ctx context.Context, id string) (nostrEvent, error) {
+	for _, relay := range n.relays {
+		ev, ok, err := n.fetchFrom(ctx, relay, id)
+		if err != nil {
+			fmt.Println("could not fetch nostr event", id, "from", relay+":", err)
+			continue
+		}
+		if ok {
+			return ev, nil
+		}
+	}
+	return nostrEvent{}, errutil.New(errutil.Tags{"msg": "nostr event not found", "id": id})
+}
+
+func (n *nostrPoster) fetchFrom(ctx context.Context, relay, id string) (nostrEvent, bool, error) {
+	conn, err := n.dial(ctx, relay)
+	if err != nil {
+		return nostrEvent{}, false, errutil.With(err)
+	}
+	defer conn.Close()
+
+	const sub = "bikehfx"
+	if err := conn.WriteJSON([]any{"REQ", sub, map[string]any{"ids": []string{id}}}); err != nil {
+		return nostrEvent{}, false, errutil.With(err)
+	}
+	defer conn.WriteJSON([]any{"CLOSE", sub})
+
+	for {
+		msg, err := readRelayMessage(conn)
+		if err != nil {
+			return nostrEvent{}, false, errutil.With(err)
+		}
+		typ, err := relayMessageType(msg)
+		if err != nil {
+			return nostrEvent{}, false, errutil.With(err)
+		}
+		switch typ {
+		case "EVENT":
+			// ["EVENT", <subscription>, <event>]
+			var ev nostrEvent
+			if len(msg) < 3 || json.Unmarshal(msg[2], &ev) != nil || ev.ID != id {
+				continue
+			}
+			return ev, true, nil
+		case "EOSE", "CLOSED":
+			return nostrEvent{}, false, nil
+		}
+	}
+}
+
+func (n *nostrPoster) dial(ctx context.Context, relay string) (*websocket.Conn, error) {
+	conn, _, err := n.dialer.DialContext(ctx, relay, nil)
+	if err != nil {
+		return nil, errutil.Witht(err, errutil.Tags{"relay": relay})
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(time.Minute)
+	}
+	conn.SetReadDeadline(deadline)
+	conn.SetWriteDeadline(deadline)
+	return conn, nil
+}
+
+func readRelayMessage(conn *websocket.Conn) ([]json.RawMessage, error) {
+	var msg []json.RawMessage
+	if err := conn.ReadJSON(&msg); err != nil {
+		return nil, errutil.With(err)
+	}
+	return msg, nil
+}
+
+func relayMessageType(msg []json.RawMessage) (string, error) {
+	if len(msg) == 0 {
+		return "", errutil.New(errutil.Tags{"msg": "empty nostr relay message"})
+	}
+	var typ string
+	if err := json.Unmarshal(msg[0], &typ); err != nil {
+		return "", errutil.With(err)
+	}
+	return typ, nil
+}
+
+// upload sends am to the NIP-96 server, authorized as in NIP-98, and returns
+// its URL.
+func (n *nostrPoster) upload(ctx context.Context, am adaptedMedia, altText string) (string, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	if altText != "" {
+		if err := mw.WriteField("alt", altText); err != nil {
+			return "", errutil.With(err)
+		}
+	}
+	if err := mw.WriteField("content_type", am.mimeType); err != nil {
+		return "", errutil.With(err)
+	}
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename="image.%s"`, strings.TrimPrefix(am.mimeType, "image/")))
+	h.Set("Content-Type", am.mimeType)
+	part, err := mw.CreatePart(h)
+	if err != nil {
+		return "", errutil.With(err)
+	}
+	if _, err := part.Write(am.b); err != nil {
+		return "", errutil.With(err)
+	}
+	if err := mw.Close(); err != nil {
+		return "", errutil.With(err)
+	}
+
+	payload := sha256.Sum256(body.Bytes())
+	auth, err := n.sign(nostrKindHTTPAuth, [][]string{
+		{"u", n.uploadURL},
+		{"method", http.MethodPost},
+		{"payload", hex.EncodeToString(payload[:])},
+	}, "")
+	if err != nil {
+		return "", errutil.With(err)
+	}
+	ab, err := json.Marshal(auth)
+	if err != nil {
+		return "", errutil.With(err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.uploadURL, &body)
+	if err != nil {
+		return "", errutil.With(err)
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("Authorization", "Nostr "+base64.StdEncoding.EncodeToString(ab))
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return "", errutil.With(err)
+	}
+	defer resp.Body.Close()
+
+	rb, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", errutil.With(err)
+	}
+
+	var out struct {
+		Status     string `json:"status"`
+		Message    string `json:"message"`
+		NIP94Event struct {
+			Tags [][]string `json:"tags"`
+		} `json:"nip94_event"`
+	}
+	if err := json.Unmarshal(rb, &out); err != nil || resp.StatusCode/100 != 2 || out.Status != "success" {
+		return "", errutil.New(errutil.Tags{"msg": "nostr upload failed", "status": resp.StatusCode, "body": string(rb)})
+	}
+	for _, t := range out.NIP94Event.Tags {
+		if len(t) >= 2 && t[0] == "url" {
+			return t[1], nil
+		}
+	}
+	return "", errutil.New(errutil.Tags{"msg": "nostr upload returned no url", "body": string(rb)})
+}
+
+// decodeNostrKey decodes a secret key given in hex or as a NIP-19 nsec.
+func decodeNostrKey(key string) ([]byte, error) {
+	var b []byte
+	if strings.HasPrefix(strings.ToLower(key), "nsec1") {
+		hrp, data, err := decodeBech32(key)
+		if err != nil {
+			return nil, errutil.With(err)
+		}
+		if hrp != "nsec" {
+			return nil, errutil.New(errutil.Tags{"msg": "not a nostr secret key", "hrp": hrp})
+		}
+		b = data
+	} else {
+		var err error
+		if b, err = hex.DecodeString(key); err != nil {
+			return nil, errutil.New(errutil.Tags{"msg": "nostr key is neither hex nor nsec"})
+		}
+	}
+	if len(b) != 32 {
+		return nil, errutil.New(errutil.Tags{"msg": "nostr key must be 32 bytes", "len": len(b)})
+	}
+	return b, nil
+}
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// decodeBech32 decodes a BIP-173 string, as NIP-19 uses, returning its
+// human-readable part and data converted to bytes.
+func decodeBech32(s string) (string, []byte, error) {
+	s = strings.ToLower(s)
+	sep := strings.LastIndexByte(s, '1')
+	if sep < 1 || sep+7 > len(s) {
+		return "", nil, errutil.New(errutil.Tags{"msg": "invalid bech32"})
+	}
+	hrp := s[:sep]
+
+	values := make([]byte, 0, len(s)-sep-1)
+	for _, c := range []byte(s[sep+1:]) {
+		v := strings.IndexByte(bech32Charset, c)
+		if v < 0 {
+			return "", nil, errutil.New(errutil.Tags{"msg": "invalid bech32 character", "char": string(c)})
+		}
+		values = append(values, byte(v))
+	}
+
+	chk := make([]byte, 0, 2*len(hrp)+1+len(values))
+	for _, c := range []byte(hrp) {
+		chk = append(chk, c>>5)
+	}
+	chk = append(chk, 0)
+	for _, c := range []byte(hrp) {
+		chk = append(chk, c&31)
+	}
+	if bech32Polymod(append(chk, values...)) != 1 {
+		return "", nil, errutil.New(errutil.Tags{"msg": "invalid bech32 checksum"})
+	}
+
+	// Regroup the 5-bit values, less the checksum, into bytes.
+	var (
+		out  []byte
+		acc  uint
+		bits uint
+	)
+	for _, v := range values[:len(values)-6] {
+		acc = acc<<5 | uint(v)
+		bits += 5
+		if bits >= 8 {
+			bits -= 8
+			out = append(out, byte(acc>>bits))
+		}
+	}
+	if bits >= 5 || acc&(1<<bits-1) != 0 {
+		return "", nil, errutil.New(errutil.Tags{"msg": "invalid bech32 padding"})
+	}
+	return hrp, out, nil
+}
+
+func bech32Polymod(values []byte) uint32 {
+	gen := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := range 5 {
+			if top>>i&1 == 1 {
+				chk ^= gen[i]
+			}
+		}
+	}
+	return chk
+}
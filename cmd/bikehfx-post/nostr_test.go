@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/gorilla/websocket"
+)
+
+// Key from the NIP-19 examples.
+const (
+	testNostrNsec = "nsec1vl029mgpspedva04g90vltkh6fvh240zqtv9k0t9af8935ke9laqsnlfe5"
+	testNostrKey  = "67dea2ed018072d675f5415ecfaed7d2597555e202d85b3d65ea4e58d2d92ffa"
+)
+
+// fakeRelay is a stand-in nostr relay that stores events with valid
+// signatures, or rejects all of them.
+type fakeRelay struct {
+	reject bool
+
+	mu     sync.Mutex
+	events map[string]nostrEvent
+	order  []string
+}
+
+func newFakeRelay(t *testing.T, reject bool) (*fakeRelay, string) {
+	r := &fakeRelay{reject: reject, events: make(map[string]nostrEvent)}
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return r, "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+func (r *fakeRelay) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var upgrader websocket.Upgrader
+	conn, err := upgrader.Upgrade(w, req, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	for {
+		var msg []json.RawMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			return
+		}
+		typ, _ := relayMessageType(msg)
+		switch typ {
+		case "EVENT":
+			var ev nostrEvent
+			json.Unmarshal(msg[1], &ev)
+			ok, reason := true, ""
+			if err := verifyNostrEvent(ev); err != nil {
+				ok, reason = false, "invalid: "+err.Error()
+			} else if r.reject {
+				ok, reason = false, "blocked: not today"
+			} else {
+				r.mu.Lock()
+				r.events[ev.ID] = ev
+				r.order = append(r.order, ev.ID)
+				r.mu.Unlock()
+			}
+			conn.WriteJSON([]any{"OK", ev.ID, ok, reason})
+
+		case "REQ":
+			var sub string
+			var filter struct {
+				IDs []string `json:"ids"`
+			}
+			json.Unmarshal(msg[1], &sub)
+			json.Unmarshal(msg[2], &filter)
+			r.mu.Lock()
+			for _, id := range filter.IDs {
+				if ev, ok := r.events[id]; ok {
+					conn.WriteJSON([]any{"EVENT", sub, ev})
+				}
+			}
+			r.mu.Unlock()
+			conn.WriteJSON([]any{"EOSE", sub})
+		}
+	}
+}
+
+func (r *fakeRelay) stored() []nostrEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var evs []nostrEvent
+	for _, id := range r.order {
+		evs = append(evs, r.events[id])
+	}
+	return evs
+}
+
+func verifyNostrEvent(ev nostrEvent) error {
+	id, err := nostrEventID(ev)
+	if err != nil {
+		return err
+	}
+	if hex.EncodeToString(id) != ev.ID {
+		return fmt.Errorf("id mismatch")
+	}
+	pb, _ := hex.DecodeString(ev.PubKey)
+	pub, err := schnorr.ParsePubKey(pb)
+	if err != nil {
+		return err
+	}
+	sb, _ := hex.DecodeString(ev.Sig)
+	sig, err := schnorr.ParseSignature(sb)
+	if err != nil {
+		return err
+	}
+	if !sig.Verify(id, pub) {
+		return fmt.Errorf("bad signature")
+	}
+	return nil
+}
+
+// newFakeNIP96Server accepts uploads authorized as in NIP-98, returning the
+// server URL and a func for the alt texts uploaded.
+func newFakeNIP96Server(t *testing.T) (string, func() []string) {
+	var (
+		mu   sync.Mutex
+		alts []string
+	)
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		ab, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(r.Header.Get("Authorization"), "Nostr "))
+		var auth nostrEvent
+		json.Unmarshal(ab, &auth)
+		payload := sha256.Sum256(body)
+		wantTags := [][]string{{"u", srv.URL + "/upload"}, {"method", "POST"}, {"payload", hex.EncodeToString(payload[:])}}
+		if err := verifyNostrEvent(auth); err != nil || auth.Kind != nostrKindHTTPAuth || !slices.EqualFunc(auth.Tags, wantTags, slices.Equal) {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"status":"error","message":"bad auth"}`)
+			return
+		}
+
+		r.Body = io.NopCloser(strings.NewReader(string(body)))
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f, fh, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.Close()
+
+		mu.Lock()
+		alts = append(alts, r.FormValue("alt"))
+		n := len(alts)
+		mu.Unlock()
+
+		fmt.Fprintf(w, `{"status":"success","nip94_event":{"tags":[["url","%s/%d-%s"],["m",%q]]}}`, srv.URL, n, fh.Filename, r.FormValue("content_type"))
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv.URL, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return alts
+	}
+}
+
+// eTags summarizes ev's e tags as "root:<id> reply:<id>".
+func eTags(ev nostrEvent) string {
+	var s []string
+	for _, t := range ev.Tags {
+		if t[0] == "e" {
+			s = append(s, t[3]+":"+t[1])
+		}
+	}
+	return strings.Join(s, " ")
+}
+
+func hasTag(ev nostrEvent, tag ...string) bool {
+	return slices.ContainsFunc(ev.Tags, func(t []string) bool { return slices.Equal(t, tag) })
+}
+
+func TestNostrPoster(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	relay, relayURL := newFakeRelay(t, false)
+	_, rejectURL := newFakeRelay(t, true)
+	uploadURL, alts := newFakeNIP96Server(t)
+
+	np, err := newNostrPoster(testNostrNsec, []string{relayURL, rejectURL}, uploadURL+"/upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pt := posterThreader{p: np, name: "nostr", meta: postMetaConfig{language: "en"}}
+	ids, err := pt.postThreadIDs(ctx, threadKey{kind: "daily", period: "20230721"}, []post{
+		{text: "579 #BikeHfx bikes counted", media: []postMedia{{b: testPNG(t, 40, 20, false), altText: "chart"}}},
+		{text: "second"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The rejecting relay does not fail the post.
+	evs := relay.stored()
+	if len(evs) != 2 || evs[0].ID != ids[0] || evs[1].ID != ids[1] {
+		t.Fatalf("relay has %d events, want the 2 posted", len(evs))
+	}
+
+	first := evs[0]
+	if first.Kind != nostrKindTextNote || !strings.HasPrefix(first.PubKey, "7e7e9c42") {
+		t.Errorf("first event kind %d from %s", first.Kind, first.PubKey)
+	}
+	img := uploadURL + "/1-image.png"
+	if first.Content != "579 #BikeHfx bikes counted\n"+img {
+		t.Errorf("first content = %q", first.Content)
+	}
+	if eTags(first) != "" || !hasTag(first, "t", "bikehfx") || !hasTag(first, "l", "en", "ISO-639-1") {
+		t.Errorf("first tags = %v", first.Tags)
+	}
+	i := slices.IndexFunc(first.Tags, func(t []string) bool { return t[0] == "imeta" })
+	if i < 0 || !slices.Contains(first.Tags[i], "url "+img) || !slices.Contains(first.Tags[i], "alt chart") || !slices.Contains(first.Tags[i], "dim 40x20") {
+		t.Errorf("first tags = %v, want imeta for the image", first.Tags)
+	}
+	if got := alts(); len(got) != 1 || got[0] != "chart" {
+		t.Errorf("uploaded alts = %v, want [chart]", got)
+	}
+
+	if got, want := eTags(evs[1]), "root:"+ids[0]; got != want {
+		t.Errorf("second e tags = %s, want %s", got, want)
+	}
+
+	// A new run resuming the thread finds its root from the relay.
+	np2, err := newNostrPoster(testNostrKey, []string{relayURL}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resumed, err := np2.post(ctx, post{text: "third", inReplyTo: ids[1]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	evs = relay.stored()
+	if got, want := eTags(evs[len(evs)-1]), "root:"+ids[0]+" reply:"+ids[1]; evs[len(evs)-1].ID != resumed || got != want {
+		t.Errorf("resumed e tags = %s, want %s", got, want)
+	}
+
+	if _, err := np2.post(ctx, post{text: "lost", inReplyTo: strings.Repeat("0", 64)}); err == nil {
+		t.Error("got no error replying to a missing event")
+	}
+	if _, err := np2.post(ctx, post{text: "no upload", media: []postMedia{{b: testPNG(t, 4, 4, false)}}}); err == nil {
+		t.Error("got no error posting media without an upload URL")
+	}
+
+	rejecting, err := newNostrPoster(testNostrKey, []string{rejectURL}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rejecting.post(ctx, post{text: "nope"}); err == nil {
+		t.Error("got no error when every relay rejected")
+	}
+}
+
+func TestDecodeNostrKey(t *testing.T) {
+	t.Parallel()
+
+	for _, key := range []string{testNostrNsec, strings.ToUpper(testNostrNsec), testNostrKey} {
+		b, err := decodeNostrKey(key)
+		if err != nil {
+			t.Errorf("%s: %v", key, err)
+			continue
+		}
+		if got := hex.EncodeToString(b); got != testNostrKey {
+			t.Errorf("%s decoded to %s, want %s", key, got, testNostrKey)
+		}
+	}
+
+	for _, key := range []string{
+		strings.Replace(testNostrNsec, "5", "6", 1), // bad checksum
+		"npub10elfcs4fr0l0r8af98jlmgdh9c8tcxjvz9qkw038js35mp4dma8qzvjptg",
+		testNostrKey[:62],
+		"not a key",
+	} {
+		if _, err := decodeNostrKey(key); err == nil {
+			t.Errorf("%s: got no error", key)
+		}
+	}
+}
+
+func TestRelayMessageType(t *testing.T) {
+	t.Parallel()
+
+	for in, want := range map[string]string{`["OK","id",true,""]`: "OK", `[]`: "", `[1]`: ""} {
+		var msg []json.RawMessage
+		if err := json.Unmarshal([]byte(in), &msg); err != nil {
+			t.Fatal(err)
+		}
+		got, err := relayMessageType(msg)
+		if got != want || (err == nil) != (want != "") {
+			t.Errorf("relayMessageType(%s) = %q, %v, want %q", in, got, err, want)
+		}
+	}
+}
@@ -3,12 +3,14 @@ package main
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"image/png"
+	"io"
+	"net/http"
 	"net/url"
 	"os"
-	"regexp"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -16,7 +18,6 @@ import (
 	"github.com/bluesky-social/indigo/api/atproto"
 	"github.com/bluesky-social/indigo/api/bsky"
 	lexutil "github.com/bluesky-social/indigo/lex/util"
-	"github.com/bluesky-social/indigo/util"
 	"github.com/bluesky-social/indigo/xrpc"
 	"github.com/graxinc/errutil"
 	"github.com/mattn/go-mastodon"
@@ -32,16 +33,106 @@ type post struct {
 	text      string
 
 	media []postMedia
+
+	meta postMeta
+
+	// idempotencyKey is the same each time this post of a thread is tried,
+	// for platforms that can drop a repeated request.
+	idempotencyKey string
+}
+
+// postMeta is how a platform should present a post. Platforms ignore what
+// they do not support.
+type postMeta struct {
+	visibility  string    // public, unlisted, private or direct; empty for the account default
+	language    string    // ISO 639 code
+	spoiler     string    // content warning shown in place of the text
+	scheduledAt time.Time // if set, when the post should appear
+}
+
+// postMetaConfig is the postMeta to use for each kind of thread.
+type postMetaConfig struct {
+	visibility kindValues
+	spoiler    kindValues
+	schedule   kindValues
+	language   string
+}
+
+func (c postMetaConfig) forKind(kind string) postMeta {
+	m := postMeta{
+		visibility: c.visibility.get(kind),
+		spoiler:    c.spoiler.get(kind),
+		language:   c.language,
+	}
+	if s := c.schedule.get(kind); s != "" {
+		// Set validated it.
+		m.scheduledAt, _ = time.Parse(time.RFC3339, s)
+	}
+	return m
 }
 
 type posterThreader struct {
 	p         poster
+	name      string
 	inReplyTo string
 	initial   string
+
+	// If journal is set, threads already posted to this platform are skipped
+	// unless force is set.
+	journal *postJournal
+	force   bool
+
+	// If set, how long the whole thread may take to post.
+	timeout time.Duration
+
+	meta postMetaConfig
+
+	// With inReplyTo set to autoInReplyTo, how many periods back the thread
+	// to chain from may be.
+	chainMaxGap int
+}
+
+// autoInReplyTo as a posterThreader's inReplyTo chains each thread to the
+// first post of the previous period's thread.
+const autoInReplyTo = "auto"
+
+// threadResult is how posting a thread to one platform went.
+type threadResult struct {
+	platform string
+	ids      []string
+	err      error
+}
+
+func (r threadResult) String() string {
+	if r.err != nil {
+		return fmt.Sprintf("%s: failed after %d posts: %v", r.platform, len(r.ids), r.err)
+	}
+	return fmt.Sprintf("%s: %d posts %v", r.platform, len(r.ids), r.ids)
+}
+
+func (t posterThreader) postThread(ctx context.Context, key threadKey, posts []post) ([]threadResult, error) {
+	if t.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.timeout)
+		defer cancel()
+	}
+
+	ids, err := t.postThreadIDs(ctx, key, posts)
+	if err != nil {
+		err = errutil.Witht(err, errutil.Tags{"platform": t.name})
+	}
+	return []threadResult{{platform: t.name, ids: ids, err: err}}, err
 }
 
-func (t posterThreader) postThread(ctx context.Context, posts []post) ([]string, error) {
+func (t posterThreader) postThreadIDs(ctx context.Context, key threadKey, posts []post) ([]string, error) {
 	inReplyTo := t.inReplyTo
+	if inReplyTo == autoInReplyTo {
+		var err error
+		inReplyTo, err = t.chainReplyTo(key)
+		if err != nil {
+			return nil, errutil.With(err)
+		}
+	}
 
 	if t.initial != "" {
 		initial := post{
@@ -50,40 +141,142 @@ func (t posterThreader) postThread(ctx context.Context, posts []post) ([]string,
 		posts = append([]post{initial}, posts...)
 	}
 
-	ids := make([]string, len(posts))
-	for i, p := range posts {
+	if lp, ok := t.p.(limitedPoster); ok {
+		posts = splitPosts(posts, lp.limits())
+	}
+
+	if len(posts) == 0 {
+		return nil, nil
+	}
+
+	meta := t.meta.forKind(key.kind)
+	// Replies cannot target a status that is only scheduled, so refuse
+	// before queuing the first post of a thread that could not be finished.
+	if !meta.scheduledAt.IsZero() && len(posts) > 1 {
+		return nil, errutil.New(errutil.Tags{"msg": "only single-post threads can be scheduled", "kind": key.kind, "period": key.period, "posts": len(posts)})
+	}
+
+	hash := threadHash(posts)
+
+	var ids []string
+	if t.journal != nil && !t.force {
+		e, ok, err := t.journal.lookup(key, t.name)
+		if err != nil {
+			return nil, errutil.With(err)
+		}
+		if ok && !e.Partial {
+			if e.Hash != hash {
+				fmt.Println("content for", key.kind, key.period, "changed since it was posted to", t.name)
+			}
+			fmt.Println("already posted", key.kind, key.period, "to", t.name, "at", e.PostedAt.Format(time.RFC3339), "skipping, use -force to repost")
+			return e.IDs, nil
+		}
+		if ok && len(e.IDs) > 0 {
+			// Resuming would post the rest of the new thread under the
+			// start of the old one.
+			if e.Hash != hash {
+				return nil, errutil.New(errutil.Tags{"msg": "content changed since the thread was partly posted, use -force to repost it", "kind": key.kind, "period": key.period, "posts": len(e.IDs)})
+			}
+			fmt.Println("resuming", key.kind, key.period, "on", t.name, "after", len(e.IDs), "posts")
+			ids = slices.Clone(e.IDs[:min(len(e.IDs), len(posts))])
+			inReplyTo = ids[len(ids)-1]
+		}
+	}
+
+	record := func() error {
+		if t.journal == nil {
+			return nil
+		}
+		return t.journal.record(postJournalEntry{
+			Kind:     key.kind,
+			Period:   key.period,
+			Platform: t.name,
+			IDs:      ids,
+			Hash:     hash,
+			PostedAt: time.Now().UTC(),
+			Partial:  len(ids) < len(posts),
+		})
+	}
+
+	if len(ids) == len(posts) {
+		// An earlier run posted everything but did not mark it complete.
+		return ids, record()
+	}
+
+	for _, p := range posts[len(ids):] {
 		p.inReplyTo = inReplyTo
+		p.meta = meta
+		p.idempotencyKey = fmt.Sprintf("%s-%s-%d-%s", key.kind, key.period, len(ids), hash[:16])
 
 		id, err := t.p.post(ctx, p)
 		if err != nil {
-			return nil, errutil.With(err)
+			return ids, errutil.With(err)
 		}
 
-		fmt.Println("posted", id)
-		ids[i] = id
+		fmt.Println(t.name, "posted", id)
+		ids = append(ids, id)
 		inReplyTo = id
+
+		// Record progress after each post so a failed run can resume.
+		if err := record(); err != nil {
+			return ids, errutil.With(err)
+		}
 	}
 
 	return ids, nil
 }
 
-type multiPosterThreader []posterThreader
+// chainReplyTo returns the first post of the previous period's thread on this
+// platform, or "" to start a new chain when there is none recent enough.
+func (t posterThreader) chainReplyTo(key threadKey) (string, error) {
+	if t.journal == nil {
+		fmt.Println("no post journal to chain", key.kind, key.period, "on", t.name, "starting a new chain")
+		return "", nil
+	}
+
+	e, ok, err := t.journal.previous(key, t.name)
+	if err != nil {
+		return "", errutil.With(err)
+	}
+	if !ok {
+		fmt.Println("no earlier", key.kind, "thread on", t.name, "starting a new chain")
+		return "", nil
+	}
+	if gap, ok := periodGap(key.kind, e.Period, key.period); !ok || gap > t.chainMaxGap {
+		fmt.Println("last", key.kind, "thread on", t.name, "was for", e.Period, "starting a new chain")
+		return "", nil
+	}
+	return e.IDs[0], nil
+}
+
+// multiPosterThreader posts threads with each of its threadPosters, such as a
+// posterThreader per platform and a feedThreader.
+type multiPosterThreader []threadPoster
 
-func (m multiPosterThreader) postThread(ctx context.Context, posts []post) ([]string, error) {
+// postThread posts to every platform at once, each with its own timeout. It
+// returns the results of each platform, in order, along with any errors.
+func (m multiPosterThreader) postThread(ctx context.Context, key threadKey, posts []post) ([]threadResult, error) {
+	results := make([][]threadResult, len(m))
+
+	var wg sync.WaitGroup
+	for i, p := range m {
+		wg.Go(func() {
+			results[i], _ = p.postThread(ctx, key, posts)
+		})
+	}
+	wg.Wait()
+
+	var out []threadResult
 	var errs []error
-	var ids []string
-	for _, p := range m {
-		is, err := p.postThread(ctx, posts)
-		if err != nil {
-			errs = append(errs, errutil.With(err))
-			continue
+	for _, rs := range results {
+		for _, r := range rs {
+			out = append(out, r)
+			if r.err != nil {
+				errs = append(errs, r.err)
+			}
 		}
-		ids = append(ids, is...)
-	}
-	if len(errs) > 0 {
-		return nil, errors.Join(errs...)
 	}
-	return ids, nil
+	return out, errors.Join(errs...)
 }
 
 type mastodonTooter struct {
@@ -107,11 +300,26 @@ func newMastodonTooter(server, clientID, clientSecret, accessToken string) (mast
 	return mastodonTooter{cl}, nil
 }
 
+// limits returns the default limit of Mastodon servers, which most keep.
+func (m mastodonTooter) limits() postLimits {
+	return postLimits{maxLength: 500}
+}
+
+// mediaLimits returns the default image limits of Mastodon servers.
+func (m mastodonTooter) mediaLimits() mediaLimits {
+	return mediaLimits{maxBytes: 16 << 20, maxPixels: 3840 * 2160}
+}
+
 func (m mastodonTooter) post(ctx context.Context, p post) (string, error) {
 	var mediaIDs []mastodon.ID
 	for _, pm := range p.media {
+		am, err := adaptMedia(pm.b, m.mediaLimits())
+		if err != nil {
+			return "", errutil.With(err)
+		}
+
 		med := &mastodon.Media{
-			File:        bytes.NewReader(pm.b),
+			File:        bytes.NewReader(am.b),
 			Description: pm.altText,
 		}
 		att, err := m.c.UploadMediaFromMedia(ctx, med)
@@ -125,7 +333,13 @@ func (m mastodonTooter) post(ctx context.Context, p post) (string, error) {
 		Status:      p.text,
 		MediaIDs:    mediaIDs,
 		InReplyToID: mastodon.ID(p.inReplyTo),
-		Visibility:  mastodon.VisibilityUnlisted,
+		Visibility:  p.meta.visibility,
+		Language:    p.meta.language,
+		SpoilerText: p.meta.spoiler,
+	}
+
+	if !p.meta.scheduledAt.IsZero() {
+		return m.scheduleStatus(ctx, t, p.meta.scheduledAt)
 	}
 
 	st, err := m.c.PostStatus(ctx, t)
@@ -136,58 +350,104 @@ func (m mastodonTooter) post(ctx context.Context, p post) (string, error) {
 	return fmt.Sprint(st.ID), nil
 }
 
-type blueskyPoster struct {
-	client *xrpc.Client
-}
+// mastodonScheduledPrefix marks the IDs of scheduled statuses, which are not
+// statuses yet and cannot be replied to.
+const mastodonScheduledPrefix = "scheduled:"
 
-func newBlueskyPoster(clientHost, handle, password string) (blueskyPoster, error) {
-	ctx := context.Background()
+// scheduleStatus queues t to be posted at, returning its scheduled status ID.
+// The client library does not support scheduling, so this posts the form
+// itself.
+func (m mastodonTooter) scheduleStatus(ctx context.Context, t *mastodon.Toot, at time.Time) (string, error) {
+	if strings.HasPrefix(string(t.InReplyToID), mastodonScheduledPrefix) {
+		return "", errutil.New(errutil.Tags{"msg": "mastodon cannot reply to a scheduled status, only single-post threads can be scheduled"})
+	}
 
-	xrpcc := &xrpc.Client{
-		Client: util.RobustHTTPClient(),
-		Host:   clientHost,
-		Auth:   &xrpc.AuthInfo{Handle: handle},
+	params := url.Values{}
+	params.Set("status", t.Status)
+	params.Set("scheduled_at", at.UTC().Format(time.RFC3339))
+	if t.InReplyToID != "" {
+		params.Set("in_reply_to_id", string(t.InReplyToID))
+	}
+	for _, id := range t.MediaIDs {
+		params.Add("media_ids[]", string(id))
+	}
+	if t.Visibility != "" {
+		params.Set("visibility", t.Visibility)
+	}
+	if t.Language != "" {
+		params.Set("language", t.Language)
+	}
+	if t.SpoilerText != "" {
+		params.Set("spoiler_text", t.SpoilerText)
 	}
 
-	auth, err := atproto.ServerCreateSession(ctx, xrpcc, &atproto.ServerCreateSession_Input{
-		Identifier: xrpcc.Auth.Handle,
-		Password:   password,
-	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(m.c.Config.Server, "/")+"/api/v1/statuses", strings.NewReader(params.Encode()))
 	if err != nil {
-		return blueskyPoster{}, errutil.With(err)
+		return "", errutil.With(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+m.c.Config.AccessToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := m.c.Do(req)
+	if err != nil {
+		return "", errutil.With(err)
 	}
+	defer resp.Body.Close()
 
-	xrpcc.Auth.AccessJwt = auth.AccessJwt
-	xrpcc.Auth.RefreshJwt = auth.RefreshJwt
-	xrpcc.Auth.Did = auth.Did
-	xrpcc.Auth.Handle = auth.Handle
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", errutil.New(errutil.Tags{"msg": "could not schedule status", "status": resp.StatusCode, "body": string(b)})
+	}
 
-	return blueskyPoster{client: xrpcc}, nil
+	var ss struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&ss); err != nil {
+		return "", errutil.With(err)
+	}
+	return mastodonScheduledPrefix + ss.ID, nil
+}
+
+func validateMastodonVisibility(v string) error {
+	switch v {
+	case mastodon.VisibilityPublic, mastodon.VisibilityUnlisted, mastodon.VisibilityFollowersOnly, mastodon.VisibilityDirectMessage:
+		return nil
+	}
+	return errutil.New(errutil.Tags{"msg": "invalid visibility", "visibility": v})
+}
+
+func validateScheduleTime(v string) error {
+	if _, err := time.Parse(time.RFC3339, v); err != nil {
+		return errutil.With(err)
+	}
+	return nil
+}
+
+type blueskyPoster struct {
+	client *xrpc.Client
+
+	// sessionPath, if set, is where the session is kept between runs.
+	sessionPath string
 }
 
-var bskyHashtagRegex = regexp.MustCompile(`#[a-zA-Z0-9_]+`)
+func (b blueskyPoster) limits() postLimits {
+	return postLimits{maxLength: 300, length: graphemeLength}
+}
+
+// mediaLimits returns the largest blob Bluesky accepts for images and the
+// size its apps display them at.
+func (b blueskyPoster) mediaLimits() mediaLimits {
+	return mediaLimits{maxBytes: 1_000_000, maxDimension: 2000}
+}
 
 func (b blueskyPoster) post(ctx context.Context, p post) (string, error) {
 	post := &bsky.FeedPost{
 		CreatedAt: time.Now().Format(time.RFC3339Nano),
 		Text:      p.text,
+		Facets:    buildFacets(ctx, p.text, b),
 	}
-
-	for _, matches := range bskyHashtagRegex.FindAllIndex([]byte(p.text), -1) {
-		facet := &bsky.RichtextFacet{
-			Index: &bsky.RichtextFacet_ByteSlice{
-				ByteStart: int64(matches[0]),
-				ByteEnd:   int64(matches[1]),
-			},
-			Features: []*bsky.RichtextFacet_Features_Elem{
-				{
-					RichtextFacet_Tag: &bsky.RichtextFacet_Tag{
-						Tag: p.text[matches[0]+1 : matches[1]], // +1 to skip the #
-					},
-				},
-			},
-		}
-		post.Facets = append(post.Facets, facet)
+	if p.meta.language != "" {
+		post.Langs = []string{p.meta.language}
 	}
 
 	if p.inReplyTo != "" {
@@ -202,7 +462,16 @@ func (b blueskyPoster) post(ctx context.Context, p post) (string, error) {
 		post.Embed = &bsky.FeedPost_Embed{EmbedImages: &bsky.EmbedImages{}}
 	}
 	for _, m := range p.media {
-		resp, err := atproto.RepoUploadBlob(ctx, b.client, bytes.NewReader(m.b))
+		am, err := adaptMedia(m.b, b.mediaLimits())
+		if err != nil {
+			return "", errutil.With(err)
+		}
+
+		var resp *atproto.RepoUploadBlob_Output
+		err = b.do(ctx, func(c *xrpc.Client) (err error) {
+			resp, err = atproto.RepoUploadBlob(ctx, c, bytes.NewReader(am.b))
+			return err
+		})
 		if err != nil {
 			return "", errutil.With(err)
 		}
@@ -210,27 +479,25 @@ func (b blueskyPoster) post(ctx context.Context, p post) (string, error) {
 			Alt: m.altText,
 			Image: &lexutil.LexBlob{
 				Ref:      resp.Blob.Ref,
-				MimeType: "image/png",
+				MimeType: am.mimeType,
 				Size:     resp.Blob.Size,
 			},
-		}
-
-		decoded, err := png.Decode(bytes.NewReader(m.b))
-		if err != nil {
-			return "", errutil.With(err)
-		}
-
-		img.AspectRatio = &bsky.EmbedDefs_AspectRatio{
-			Width:  int64(decoded.Bounds().Dx()),
-			Height: int64(decoded.Bounds().Dy()),
+			AspectRatio: &bsky.EmbedDefs_AspectRatio{
+				Width:  int64(am.width),
+				Height: int64(am.height),
+			},
 		}
 		post.Embed.EmbedImages.Images = append(post.Embed.EmbedImages.Images, img)
 	}
 
-	resp, err := atproto.RepoCreateRecord(ctx, b.client, &atproto.RepoCreateRecord_Input{
-		Collection: "app.bsky.feed.post",
-		Repo:       b.client.Auth.Did,
-		Record:     &lexutil.LexiconTypeDecoder{Val: post},
+	var resp *atproto.RepoCreateRecord_Output
+	err := b.do(ctx, func(c *xrpc.Client) (err error) {
+		resp, err = atproto.RepoCreateRecord(ctx, c, &atproto.RepoCreateRecord_Input{
+			Collection: "app.bsky.feed.post",
+			Repo:       c.Auth.Did,
+			Record:     &lexutil.LexiconTypeDecoder{Val: post},
+		})
+		return err
 	})
 	if err != nil {
 		return "", errutil.With(err)
@@ -268,7 +535,11 @@ func (b blueskyPoster) resolveRef(ctx context.Context, ref string) (root, parent
 		ref = "at://" + profile + "/app.bsky.feed.post/" + recordID
 	}
 
-	resp, err := bsky.FeedGetPostThread(ctx, b.client, 1, 1, ref)
+	var resp *bsky.FeedGetPostThread_Output
+	err := b.do(ctx, func(c *xrpc.Client) (err error) {
+		resp, err = bsky.FeedGetPostThread(ctx, c, 1, 1, ref)
+		return err
+	})
 	if err != nil {
 		return nil, nil, errutil.With(err)
 	}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/graxinc/errutil"
+)
+
+// threadKey identifies what a thread is about, such as the daily post for a
+// day. Each period gets its own thread, and periods of one kind sort by date
+// as strings.
+type threadKey struct {
+	kind   string
+	period string
+}
+
+// postJournal records threads posted to each platform so reruns can skip
+// them. It is a JSON file rewritten on each change.
+type postJournal struct {
+	path string
+
+	mu sync.Mutex
+}
+
+type postJournalEntry struct {
+	Kind     string    `json:"kind"`
+	Period   string    `json:"period"`
+	Platform string    `json:"platform"`
+	IDs      []string  `json:"ids"`
+	Hash     string    `json:"hash"`
+	PostedAt time.Time `json:"posted_at"`
+
+	// Partial is set while only the first len(IDs) posts have been posted.
+	Partial bool `json:"partial,omitempty"`
+}
+
+func (e postJournalEntry) matches(key threadKey, platform string) bool {
+	return e.Kind == key.kind && e.Period == key.period && e.Platform == platform
+}
+
+func (j *postJournal) lookup(key threadKey, platform string) (postJournalEntry, bool, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entries, err := j.read()
+	if err != nil {
+		return postJournalEntry{}, false, errutil.With(err)
+	}
+	i := slices.IndexFunc(entries, func(e postJournalEntry) bool { return e.matches(key, platform) })
+	if i < 0 {
+		return postJournalEntry{}, false, nil
+	}
+	return entries[i], true, nil
+}
+
+// record stores e, replacing any entry for the same kind, period and platform.
+func (j *postJournal) record(e postJournalEntry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entries, err := j.read()
+	if err != nil {
+		return errutil.With(err)
+	}
+	entries = slices.DeleteFunc(entries, func(o postJournalEntry) bool {
+		return o.matches(threadKey{kind: e.Kind, period: e.Period}, e.Platform)
+	})
+	entries = append(entries, e)
+
+	b, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return errutil.With(err)
+	}
+	return writeFileAtomic(j.path, b)
+}
+
+func (j *postJournal) read() ([]postJournalEntry, error) {
+	b, err := os.ReadFile(j.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errutil.With(err)
+	}
+
+	var out []postJournalEntry
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, errutil.With(err)
+	}
+	return out, nil
+}
+
+// threadHash summarizes the text and media of posts, to tell whether a rerun
+// would post the same content.
+func threadHash(posts []post) string {
+	h := sha256.New()
+	for _, p := range posts {
+		h.Write([]byte(p.text))
+		h.Write([]byte{0})
+		for _, m := range p.media {
+			h.Write(m.b)
+			h.Write([]byte(m.altText))
+			h.Write([]byte{0})
+		}
+		h.Write([]byte{1})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// previous returns the latest thread of key's kind posted to platform for a
+// period before key's, skipping threads that are only scheduled.
+func (j *postJournal) previous(key threadKey, platform string) (postJournalEntry, bool, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entries, err := j.read()
+	if err != nil {
+		return postJournalEntry{}, false, errutil.With(err)
+	}
+
+	var out postJournalEntry
+	var found bool
+	for _, e := range entries {
+		// Scheduled statuses cannot be replied to until they are posted.
+		if e.Kind != key.kind || e.Platform != platform || len(e.IDs) == 0 || strings.HasPrefix(e.IDs[0], mastodonScheduledPrefix) {
+			continue
+		}
+		if e.Period >= key.period || (found && e.Period <= out.Period) {
+			continue
+		}
+		out, found = e, true
+	}
+	return out, found, nil
+}
+
+// periodLayouts are the time layouts of each kind's periods.
+var periodLayouts = map[string]string{"daily": "20060102", "weekly": "20060102", "monthly": "200601", "yearly": "2006"}
+
+// title describes the thread by its kind and period, such as "Week review:
+// week ending Sat Jul 22, 2023", for places that need a heading.
+func (k threadKey) title() string {
+	layout, ok := periodLayouts[k.kind]
+	if !ok {
+		return k.kind + " " + k.period
+	}
+	t, err := time.Parse(layout, k.period)
+	if err != nil {
+		return k.kind + " " + k.period
+	}
+
+	switch k.kind {
+	case "daily":
+		return "Day review: " + t.Format("Mon Jan 2, 2006")
+	case "weekly":
+		return "Week review: week ending " + t.AddDate(0, 0, 6).Format("Mon Jan 2, 2006")
+	case "monthly":
+		return "Month review: " + t.Format("January 2006")
+	default:
+		return "Year review: " + t.Format("2006")
+	}
+}
+
+// periodGap returns how many periods of kind there are from period a to
+// period b.
+func periodGap(kind, a, b string) (int, bool) {
+	layout, ok := periodLayouts[kind]
+	if !ok {
+		return 0, false
+	}
+	ta, err := time.Parse(layout, a)
+	if err != nil {
+		return 0, false
+	}
+	tb, err := time.Parse(layout, b)
+	if err != nil {
+		return 0, false
+	}
+
+	switch kind {
+	case "daily":
+		return int(tb.Sub(ta).Hours()/24 + 0.5), true
+	case "weekly":
+		return int(tb.Sub(ta).Hours()/24/7 + 0.5), true
+	case "monthly":
+		return (tb.Year()-ta.Year())*12 + int(tb.Month()-ta.Month()), true
+	default:
+		return tb.Year() - ta.Year(), true
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakePoster struct {
+	posts []post
+}
+
+func (f *fakePoster) post(ctx context.Context, p post) (string, error) {
+	f.posts = append(f.posts, p)
+	return fmt.Sprintf("id-%d", len(f.posts)), nil
+}
+
+func TestPosterThreaderJournal(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	journal := &postJournal{path: filepath.Join(t.TempDir(), "posts.json")}
+	key := threadKey{kind: "daily", period: "20230721"}
+	posts := []post{{text: "one"}, {text: "two"}}
+
+	fp := &fakePoster{}
+	pt := posterThreader{p: fp, name: "fake", journal: journal}
+
+	ids, err := pt.postThreadIDs(ctx, key, posts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"id-1", "id-2"}; !slices.Equal(ids, want) {
+		t.Fatalf("ids = %v, want %v", ids, want)
+	}
+	if fp.posts[1].inReplyTo != "id-1" {
+		t.Errorf("second post in reply to %q, want id-1", fp.posts[1].inReplyTo)
+	}
+
+	// A rerun returns the journaled IDs without posting.
+	ids, err = pt.postThreadIDs(ctx, key, posts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fp.posts) != 2 || !slices.Equal(ids, []string{"id-1", "id-2"}) {
+		t.Fatalf("rerun posted %d times with ids %v, want skip", len(fp.posts), ids)
+	}
+
+	// Other platforms and periods are journaled separately.
+	other := &fakePoster{}
+	if _, err := (posterThreader{p: other, name: "other", journal: journal}).postThread(ctx, key, posts); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pt.postThread(ctx, threadKey{kind: "daily", period: "20230722"}, posts); err != nil {
+		t.Fatal(err)
+	}
+	if len(other.posts) != 2 || len(fp.posts) != 4 {
+		t.Fatalf("got %d other and %d fake posts, want 2 and 4", len(other.posts), len(fp.posts))
+	}
+
+	pt.force = true
+	ids, err = pt.postThreadIDs(ctx, key, posts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"id-5", "id-6"}; !slices.Equal(ids, want) {
+		t.Fatalf("forced ids = %v, want %v", ids, want)
+	}
+
+	e, ok, err := journal.lookup(key, "fake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || !slices.Equal(e.IDs, []string{"id-5", "id-6"}) || e.Hash != threadHash(posts) {
+		t.Errorf("journal entry = %+v, want forced IDs and hash", e)
+	}
+}
+
+func TestSavePosterJournal(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	ctx := context.Background()
+	journal := &postJournal{path: "posts.json"}
+	key := threadKey{kind: "weekly", period: "20230723"}
+	posts := []post{{text: "week", media: []postMedia{{b: []byte("png"), altText: "alt"}}}}
+
+	for range 2 {
+		pt := posterThreader{p: &savePoster{}, name: "test", journal: journal}
+		if _, err := pt.postThread(ctx, key, posts); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	matches, err := filepath.Glob("post-*.post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 {
+		t.Fatalf("got posts %v, want one", matches)
+	}
+	if b, err := os.ReadFile("post-01.post.image-00.alt"); err != nil || string(b) != "alt" {
+		t.Errorf("alt text = %q, %v", b, err)
+	}
+}
+
+type failingPoster struct {
+	fakePoster
+	failAt int
+}
+
+func (f *failingPoster) post(ctx context.Context, p post) (string, error) {
+	if len(f.posts)+1 == f.failAt {
+		f.failAt = 0
+		return "", errors.New("post failed")
+	}
+	return f.fakePoster.post(ctx, p)
+}
+
+func TestMultiPosterThreaderResume(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	journal := &postJournal{path: filepath.Join(t.TempDir(), "posts.json")}
+	key := threadKey{kind: "daily", period: "20230721"}
+	posts := []post{{text: "one"}, {text: "two"}, {text: "three"}}
+
+	mastodon := &fakePoster{}
+	bsky := &failingPoster{failAt: 3}
+	mpt := multiPosterThreader{
+		posterThreader{p: mastodon, name: "mastodon", journal: journal},
+		posterThreader{p: bsky, name: "bluesky", journal: journal},
+	}
+
+	results, err := mpt.postThread(ctx, key, posts)
+	if err == nil {
+		t.Fatal("expected error from failed post")
+	}
+	if len(results) != 2 || results[0].err != nil || !slices.Equal(results[0].ids, []string{"id-1", "id-2", "id-3"}) {
+		t.Fatalf("mastodon result = %v, want 3 posts", results)
+	}
+	if results[1].platform != "bluesky" || results[1].err == nil || !slices.Equal(results[1].ids, []string{"id-1", "id-2"}) {
+		t.Fatalf("bluesky result = %v, want failure after 2 posts", results[1])
+	}
+	if e, _, _ := journal.lookup(key, "bluesky"); !e.Partial || len(e.IDs) != 2 {
+		t.Fatalf("bluesky journal entry = %+v, want partial with 2 IDs", e)
+	}
+
+	// Content that changed since is not resumed under the old thread.
+	changed := []post{{text: "one"}, {text: "two, revised"}, {text: "three"}}
+	if _, err := mpt.postThread(ctx, key, changed); err == nil || !strings.Contains(err.Error(), "-force") {
+		t.Fatalf("got %v resuming changed content, want an error suggesting -force", err)
+	}
+	if len(bsky.posts) != 2 {
+		t.Fatalf("bluesky got %d posts, want none added for changed content", len(bsky.posts))
+	}
+
+	// The rerun leaves mastodon alone and continues the bluesky thread.
+	if _, err := mpt.postThread(ctx, key, posts); err != nil {
+		t.Fatal(err)
+	}
+	if len(mastodon.posts) != 3 {
+		t.Errorf("mastodon got %d posts, want 3", len(mastodon.posts))
+	}
+	if len(bsky.posts) != 3 || bsky.posts[2].text != "three" || bsky.posts[2].inReplyTo != "id-2" {
+		t.Errorf("bluesky posts = %+v, want third post replying to id-2", bsky.posts)
+	}
+	if e, _, _ := journal.lookup(key, "bluesky"); e.Partial || !slices.Equal(e.IDs, []string{"id-1", "id-2", "id-3"}) {
+		t.Errorf("bluesky journal entry = %+v, want complete", e)
+	}
+}
+
+func TestPosterThreaderChain(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	journal := &postJournal{path: filepath.Join(t.TempDir(), "posts.json")}
+	fp := &fakePoster{}
+	pt := posterThreader{p: fp, name: "fake", inReplyTo: autoInReplyTo, journal: journal, chainMaxGap: 2}
+
+	steps := []struct {
+		key  threadKey
+		want string // first post in reply to
+	}{
+		{threadKey{kind: "daily", period: "20230720"}, ""},
+		{threadKey{kind: "daily", period: "20230721"}, "id-1"},
+		{threadKey{kind: "weekly", period: "20230717"}, ""},
+		{threadKey{kind: "daily", period: "20230723"}, "id-3"},
+		{threadKey{kind: "daily", period: "20230801"}, ""},
+		{threadKey{kind: "weekly", period: "20230724"}, "id-5"},
+	}
+	for _, s := range steps {
+		n := len(fp.posts)
+		if _, err := pt.postThreadIDs(ctx, s.key, []post{{text: s.key.period + " one"}, {text: s.key.period + " two"}}); err != nil {
+			t.Fatal(err)
+		}
+		if got := fp.posts[n].inReplyTo; got != s.want {
+			t.Errorf("%v %v first post in reply to %q, want %q", s.key.kind, s.key.period, got, s.want)
+		}
+	}
+
+	// A thread only scheduled so far is skipped for the one before it.
+	if err := journal.record(postJournalEntry{Kind: "weekly", Period: "20230731", Platform: "fake", IDs: []string{mastodonScheduledPrefix + "9"}}); err != nil {
+		t.Fatal(err)
+	}
+	n := len(fp.posts)
+	if _, err := pt.postThreadIDs(ctx, threadKey{kind: "weekly", period: "20230807"}, []post{{text: "one"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := fp.posts[n].inReplyTo; got != "id-11" {
+		t.Errorf("first post in reply to %q, want id-11 from the last posted week", got)
+	}
+
+	// Without a journal there is nothing to chain from.
+	pt.journal = nil
+	n = len(fp.posts)
+	if _, err := pt.postThreadIDs(ctx, threadKey{kind: "daily", period: "20230802"}, []post{{text: "one"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := fp.posts[n].inReplyTo; got != "" {
+		t.Errorf("first post in reply to %q without a journal, want a new chain", got)
+	}
+}
+
+func TestPeriodGap(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		kind, a, b string
+		want       int
+	}{
+		{"daily", "20230720", "20230721", 1},
+		{"daily", "20231231", "20240101", 1},
+		{"weekly", "20230717", "20230731", 2},
+		{"monthly", "202311", "202402", 3},
+		{"yearly", "2022", "2023", 1},
+	}
+	for _, tc := range cases {
+		if got, ok := periodGap(tc.kind, tc.a, tc.b); !ok || got != tc.want {
+			t.Errorf("periodGap(%q, %q, %q) = %d, %v, want %d", tc.kind, tc.a, tc.b, got, ok, tc.want)
+		}
+	}
+	if _, ok := periodGap("daily", "2023", "20230721"); ok {
+		t.Error("got ok for a malformed period")
+	}
+}
+
+func TestPostPeriodThreads(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	journal := &postJournal{path: filepath.Join(t.TempDir(), "posts.json")}
+	fp := &fakePoster{}
+	pt := posterThreader{p: fp, name: "fake", journal: journal}
+
+	var built int
+	run := func(weeks ...string) {
+		t.Helper()
+		var threads []periodThread
+		for _, week := range weeks {
+			weekt, err := time.Parse("20060102", week)
+			if err != nil {
+				t.Fatal(err)
+			}
+			key := threadKey{kind: "weekly", period: weekStart(weekt).Format("20060102")}
+			threads, err = addPeriodThread(threads, key, func() ([]post, []recordJournalEntry, error) {
+				built++
+				return []post{{text: key.period}}, nil, nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := postPeriodThreads(ctx, pt, nil, threads); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Two days in the week of Jul 16 make one thread.
+	run("20230719", "20230721", "20230726")
+	if built != 2 || len(fp.posts) != 2 || fp.posts[0].text != "20230716" || fp.posts[1].text != "20230723" || fp.posts[1].inReplyTo != "" {
+		t.Fatalf("built %d, posted %+v, want separate threads for 20230716 and 20230723", built, fp.posts)
+	}
+
+	// A later run naming another day of an already posted week skips it.
+	run("20230717")
+	if len(fp.posts) != 2 {
+		t.Fatalf("got %d posts, want the week of Jul 16 not reposted", len(fp.posts))
+	}
+	for _, period := range []string{"20230716", "20230723"} {
+		if _, ok, err := journal.lookup(threadKey{kind: "weekly", period: period}, "fake"); err != nil || !ok {
+			t.Errorf("no journal entry for %s: %v", period, err)
+		}
+	}
+}
+
+func TestThreadKeyTitle(t *testing.T) {
+	t.Parallel()
+
+	for key, want := range map[threadKey]string{
+		{kind: "daily", period: "20230721"}:  "Day review: Fri Jul 21, 2023",
+		{kind: "weekly", period: "20230716"}: "Week review: week ending Sat Jul 22, 2023",
+		{kind: "monthly", period: "202307"}:  "Month review: July 2023",
+		{kind: "yearly", period: "2023"}:     "Year review: 2023",
+		{kind: "other", period: "x"}:         "other x",
+	} {
+		if got := key.title(); got != want {
+			t.Errorf("%v title = %q, want %q", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
+)
+
+// postLimits describe how long a post's text may be on a platform.
+type postLimits struct {
+	maxLength int              // 0 for no limit
+	length    func(string) int // defaults to counting runes
+}
+
+func (l postLimits) len(s string) int {
+	if l.length == nil {
+		return utf8.RuneCountInString(s)
+	}
+	return l.length(s)
+}
+
+// limitedPoster is implemented by posters whose platforms limit post length.
+type limitedPoster interface {
+	limits() postLimits
+}
+
+func graphemeLength(s string) int {
+	return uniseg.GraphemeClusterCount(s)
+}
+
+// splitPosts splits posts that are too long into continuation posts.
+func splitPosts(posts []post, lim postLimits) []post {
+	if lim.maxLength <= 0 {
+		return posts
+	}
+	var out []post
+	for _, p := range posts {
+		out = append(out, splitPost(p, lim)...)
+	}
+	return out
+}
+
+// splitPost splits p at line boundaries into parts that fit lim. Marker notes
+// at the end of p go with each part using their marker, and media stays with
+// the first part. A single line longer than the limit is left whole.
+func splitPost(p post, lim postLimits) []post {
+	if lim.len(p.text) <= lim.maxLength {
+		return []post{p}
+	}
+
+	lines, notes := splitPostNotes(p.text)
+
+	partText := func(lines []string, first bool) string {
+		text := strings.TrimSpace(strings.Join(lines, "\n"))
+		var used []string
+		for _, n := range notes {
+			if (n.marker == "" && first) || (n.marker != "" && linesUseMarker(lines, n.marker)) {
+				used = append(used, n.text)
+			}
+		}
+		if len(used) > 0 {
+			text += "\n\n" + strings.Join(used, "\n")
+		}
+		return text
+	}
+
+	var parts [][]string
+	var cur []string
+	for _, l := range lines {
+		if len(cur) == 0 && strings.TrimSpace(l) == "" {
+			continue
+		}
+		next := append(cur[:len(cur):len(cur)], l)
+		if len(cur) > 0 && lim.len(partText(next, len(parts) == 0)) > lim.maxLength {
+			parts = append(parts, cur)
+			cur = nil
+			if strings.TrimSpace(l) == "" {
+				continue
+			}
+			next = []string{l}
+		}
+		cur = next
+	}
+	if len(cur) > 0 {
+		parts = append(parts, cur)
+	}
+
+	out := make([]post, 0, len(parts))
+	for i, part := range parts {
+		q := post{inReplyTo: p.inReplyTo, text: partText(part, i == 0)}
+		if i == 0 {
+			q.media = p.media
+		}
+		out = append(out, q)
+	}
+	return out
+}
+
+type postNote struct {
+	marker string // "" for notes that go with the first part
+	text   string
+}
+
+// splitPostNotes separates the marker notes paragraph written by
+// appendPostMarkerNotes from the rest of text.
+func splitPostNotes(text string) ([]string, []postNote) {
+	i := strings.LastIndex(text, "\n\n")
+	if i < 0 {
+		return strings.Split(text, "\n"), nil
+	}
+
+	var notes []postNote
+	for _, l := range strings.Split(text[i+2:], "\n") {
+		n, ok := parsePostNote(l)
+		if !ok {
+			return strings.Split(text, "\n"), nil
+		}
+		notes = append(notes, n)
+	}
+	return strings.Split(text[:i], "\n"), notes
+}
+
+func parsePostNote(line string) (postNote, bool) {
+	for _, k := range []recordKind{recordKindAllTime, recordKindYTD} {
+		if line == recordNote(k) {
+			return postNote{marker: recordSymbol(k), text: line}, true
+		}
+	}
+	if line == partialDataNote {
+		return postNote{marker: counterStatusSymbol(counterDataStatusPartial), text: line}, true
+	}
+	if strings.HasPrefix(line, sumRecordNotePrefix) {
+		return postNote{text: line}, true
+	}
+	return postNote{}, false
+}
+
+// linesUseMarker reports whether any line's leading count carries marker.
+// "**" and "*" are told apart so a year-to-date record does not pull in the
+// all-time note.
+func linesUseMarker(lines []string, marker string) bool {
+	for _, l := range lines {
+		field, _, _ := strings.Cut(l, " ")
+		field = strings.TrimLeft(field, "0123456789,")
+		switch marker {
+		case "**":
+			if strings.HasPrefix(field, "**") {
+				return true
+			}
+		case "*":
+			if strings.HasPrefix(field, "*") && !strings.HasPrefix(field, "**") {
+				return true
+			}
+		default:
+			if strings.Contains(field, marker) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSplitPost(t *testing.T) {
+	t.Parallel()
+
+	text := strings.Join([]string{
+		"579** #BikeHfx bikes counted Fri Jul 21",
+		"",
+		"123** Apple",
+		"456* Banana",
+		"0! Coconut",
+		"",
+		"** all-time record",
+		"* year-to-date record",
+		"Total record compares only the 3 of 4 counters reporting",
+		"! partial data",
+	}, "\n")
+	media := []postMedia{{b: []byte("png"), altText: "chart"}}
+
+	cases := []struct {
+		name string
+		max  int
+		want []string
+	}{
+		{
+			name: "Fits",
+			max:  500,
+			want: []string{text},
+		},
+		{
+			name: "NotesWithLines",
+			max:  85,
+			want: []string{
+				"579** #BikeHfx bikes counted Fri Jul 21\n\n** all-time record\nTotal record compares only the 3 of 4 counters reporting",
+				"123** Apple\n456* Banana\n\n** all-time record\n* year-to-date record",
+				"0! Coconut\n\n! partial data",
+			},
+		},
+		{
+			name: "LinePerPost",
+			max:  10,
+			want: []string{
+				"579** #BikeHfx bikes counted Fri Jul 21\n\n** all-time record\nTotal record compares only the 3 of 4 counters reporting",
+				"123** Apple\n\n** all-time record",
+				"456* Banana\n\n* year-to-date record",
+				"0! Coconut\n\n! partial data",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			parts := splitPost(post{inReplyTo: "parent", text: text, media: media}, postLimits{maxLength: tc.max})
+
+			var got []string
+			for i, p := range parts {
+				got = append(got, p.text)
+				if p.inReplyTo != "parent" {
+					t.Errorf("part %d in reply to %q, want parent", i, p.inReplyTo)
+				}
+				if (i == 0) != (len(p.media) == 1) {
+					t.Errorf("part %d has %d media, want media only on the first part", i, len(p.media))
+				}
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("got parts:\n%q\nwant:\n%q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSplitPostGraphemes(t *testing.T) {
+	t.Parallel()
+
+	// Each flag is one grapheme but several runes.
+	text := "🇨🇦🇨🇦🇨🇦\n🇨🇦🇨🇦🇨🇦"
+	lim := postLimits{maxLength: 7, length: graphemeLength}
+	if got := splitPost(post{text: text}, lim); len(got) != 1 {
+		t.Errorf("got %d parts, want 1", len(got))
+	}
+	lim.length = nil
+	if got := splitPost(post{text: text}, lim); len(got) != 2 {
+		t.Errorf("got %d parts counting runes, want 2", len(got))
+	}
+}
+
+type limitedFakePoster struct {
+	fakePoster
+	max int
+}
+
+func (l *limitedFakePoster) limits() postLimits {
+	return postLimits{maxLength: l.max}
+}
+
+func TestPosterThreaderSplits(t *testing.T) {
+	t.Parallel()
+
+	fp := &limitedFakePoster{max: 12}
+	pt := posterThreader{p: fp, name: "fake", inReplyTo: "root"}
+
+	ids, err := pt.postThreadIDs(context.Background(), threadKey{kind: "daily", period: "20230721"}, []post{{text: "first line\nsecond line"}, {text: "short"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 3 {
+		t.Fatalf("got %d posts, want 3", len(ids))
+	}
+
+	var got []string
+	for _, p := range fp.posts {
+		got = append(got, p.inReplyTo+": "+p.text)
+	}
+	want := []string{"root: first line", "id-1: second line", "id-2: short"}
+	if !slices.Equal(got, want) {
+		t.Errorf("posted %q, want %q", got, want)
+	}
+}
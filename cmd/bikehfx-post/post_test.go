@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// blockingPoster waits for release before posting, or until ctx is done.
+type blockingPoster struct {
+	fakePoster
+	release <-chan struct{}
+}
+
+func (b *blockingPoster) post(ctx context.Context, p post) (string, error) {
+	select {
+	case <-b.release:
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	return b.fakePoster.post(ctx, p)
+}
+
+// signalingPoster closes done after posting.
+type signalingPoster struct {
+	fakePoster
+	done chan struct{}
+}
+
+func (s *signalingPoster) post(ctx context.Context, p post) (string, error) {
+	id, err := s.fakePoster.post(ctx, p)
+	close(s.done)
+	return id, err
+}
+
+func TestMultiPosterThreaderConcurrent(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	key := threadKey{kind: "daily", period: "20230721"}
+	posts := []post{{text: "one"}}
+
+	// The first platform only posts once the second has, so posting them in
+	// turn would never finish.
+	done := make(chan struct{})
+	mpt := multiPosterThreader{
+		posterThreader{p: &blockingPoster{release: done}, name: "slow", timeout: 10 * time.Second},
+		posterThreader{p: &signalingPoster{done: done}, name: "fast"},
+	}
+	results, err := mpt.postThread(ctx, key, posts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].platform != "slow" || len(results[0].ids) != 1 || results[1].platform != "fast" || len(results[1].ids) != 1 {
+		t.Fatalf("results = %v, want one post on each platform", results)
+	}
+
+	// A platform that times out does not affect the others.
+	mpt = multiPosterThreader{
+		posterThreader{p: &blockingPoster{}, name: "stuck", timeout: 10 * time.Millisecond},
+		posterThreader{p: &fakePoster{}, name: "ok"},
+	}
+	results, err = mpt.postThread(ctx, key, posts)
+	if err == nil || !strings.Contains(err.Error(), "deadline exceeded") {
+		t.Fatalf("err = %v, want deadline exceeded", err)
+	}
+	if results[0].err == nil || results[1].err != nil || len(results[1].ids) != 1 {
+		t.Fatalf("results = %v, want stuck to time out and ok to post", results)
+	}
+}
+
+func TestKindValues(t *testing.T) {
+	t.Parallel()
+
+	kv := kindValues{validate: validateMastodonVisibility}
+	for _, s := range []string{"unlisted", "weekly=public", "yearly=private"} {
+		if err := kv.Set(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for kind, want := range map[string]string{"daily": "unlisted", "weekly": "public", "yearly": "private"} {
+		if got := kv.get(kind); got != want {
+			t.Errorf("get(%q) = %q, want %q", kind, got, want)
+		}
+	}
+	if got, want := kv.String(), "unlisted,weekly=public,yearly=private"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	if err := kv.Set("daily=loud"); err == nil {
+		t.Error("got no error for an invalid visibility")
+	}
+
+	// Setting only some kinds keeps the defaults for the others.
+	kv = kindValues{def: "unlisted", vals: map[string]string{"weekly": "public"}, validate: validateMastodonVisibility}
+	if err := kv.Set("monthly=public"); err != nil {
+		t.Fatal(err)
+	}
+	for kind, want := range map[string]string{"daily": "unlisted", "weekly": "public", "monthly": "public"} {
+		if got := kv.get(kind); got != want {
+			t.Errorf("after per-kind Set, get(%q) = %q, want %q", kind, got, want)
+		}
+	}
+
+	// Values may hold commas and, after the first, equals signs.
+	var spoiler kindValues
+	for _, s := range []string{"yearly=Year in review, with charts", "=a=b"} {
+		if err := spoiler.Set(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for kind, want := range map[string]string{"yearly": "Year in review, with charts", "daily": "a=b"} {
+		if got := spoiler.get(kind); got != want {
+			t.Errorf("spoiler get(%q) = %q, want %q", kind, got, want)
+		}
+	}
+}
+
+func TestMastodonTooterMeta(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu    sync.Mutex
+		forms []url.Values
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		mu.Lock()
+		forms = append(forms, r.PostForm)
+		n := len(forms)
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		if r.PostForm.Get("scheduled_at") != "" {
+			fmt.Fprintf(w, `{"id":"%d","scheduled_at":%q}`, n, r.PostForm.Get("scheduled_at"))
+			return
+		}
+		fmt.Fprintf(w, `{"id":"%d"}`, n)
+	}))
+	t.Cleanup(srv.Close)
+
+	m := mastodonTooter{mastodon.NewClient(&mastodon.Config{Server: srv.URL, AccessToken: "token"})}
+	ctx := context.Background()
+
+	id, err := m.post(ctx, post{text: "weekly", meta: postMeta{visibility: "public", language: "en", spoiler: "numbers"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "1" {
+		t.Errorf("id = %q, want 1", id)
+	}
+	if f := forms[0]; f.Get("visibility") != "public" || f.Get("language") != "en" || f.Get("spoiler_text") != "numbers" || f.Has("scheduled_at") {
+		t.Errorf("posted %v, want public, en and the spoiler", f)
+	}
+
+	at := time.Date(2027, 1, 1, 13, 0, 0, 0, time.UTC)
+	id, err = m.post(ctx, post{text: "yearly", inReplyTo: "1", meta: postMeta{visibility: "unlisted", scheduledAt: at}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "scheduled:2" {
+		t.Errorf("id = %q, want scheduled:2", id)
+	}
+	if f := forms[1]; f.Get("scheduled_at") != "2027-01-01T13:00:00Z" || f.Get("in_reply_to_id") != "1" || f.Get("visibility") != "unlisted" {
+		t.Errorf("scheduled %v, want the time, reply and visibility", f)
+	}
+
+	// Scheduled statuses cannot be replied to.
+	if _, err := m.post(ctx, post{text: "next", inReplyTo: id, meta: postMeta{scheduledAt: at}}); err == nil {
+		t.Error("got no error replying to a scheduled status")
+	}
+}
+
+func TestPosterThreaderMeta(t *testing.T) {
+	t.Parallel()
+
+	var cfg postMetaConfig
+	cfg.language = "en"
+	for _, s := range []string{"unlisted", "weekly=public"} {
+		if err := cfg.visibility.Set(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fp := &fakePoster{}
+	pt := posterThreader{p: fp, name: "fake", initial: "hello", meta: cfg}
+	ctx := context.Background()
+
+	if _, err := pt.postThreadIDs(ctx, threadKey{kind: "daily", period: "20230721"}, []post{{text: "day"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pt.postThreadIDs(ctx, threadKey{kind: "weekly", period: "20230717"}, []post{{text: "week"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, p := range fp.posts {
+		got = append(got, p.text+":"+p.meta.visibility+":"+p.meta.language)
+	}
+	want := []string{"hello:unlisted:en", "day:unlisted:en", "hello:public:en", "week:public:en"}
+	if !slices.Equal(got, want) {
+		t.Errorf("posted %q, want %q", got, want)
+	}
+
+	// A scheduled thread is refused before anything is queued unless it is a
+	// single post.
+	cfg.schedule.validate = validateScheduleTime
+	if err := cfg.schedule.Set("yearly=2027-01-01T09:00:00-04:00"); err != nil {
+		t.Fatal(err)
+	}
+	pt = posterThreader{p: fp, name: "fake", meta: cfg}
+	n := len(fp.posts)
+	if _, err := pt.postThreadIDs(ctx, threadKey{kind: "yearly", period: "2026"}, []post{{text: "year"}, {text: "more"}}); err == nil {
+		t.Error("got no error scheduling a two-post thread")
+	}
+	if len(fp.posts) != n {
+		t.Errorf("queued %d posts of a thread that cannot be scheduled", len(fp.posts)-n)
+	}
+	if _, err := pt.postThreadIDs(ctx, threadKey{kind: "yearly", period: "2026"}, []post{{text: "year"}}); err != nil {
+		t.Fatal(err)
+	}
+	if p := fp.posts[len(fp.posts)-1]; p.meta.scheduledAt.IsZero() {
+		t.Errorf("single post meta = %+v, want scheduled", p.meta)
+	}
+}
@@ -1,14 +1,99 @@
 package main
 
 import (
+	"cmp"
 	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
 	"strings"
+	"text/tabwriter"
 	"time"
 
 	"github.com/danp/counterbase/directory"
 	"github.com/graxinc/errutil"
+	"github.com/peterbourgon/ff/v3/ffcli"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
 )
 
+func newRecordsCmd(rootConfig *rootConfig) *ffcli.Command {
+	rebuildFS := flag.NewFlagSet("bikehfx-post records rebuild", flag.ExitOnError)
+
+	rebuildCmd := &ffcli.Command{
+		Name:       "rebuild",
+		ShortUsage: "bikehfx-post -state-dir <dir> records rebuild",
+		ShortHelp:  "recompute the record index from scratch, seeding it if empty",
+		FlagSet:    rebuildFS,
+		Exec: func(ctx context.Context, args []string) error {
+			if rootConfig.ri == nil {
+				return errutil.New(errutil.Tags{"flag": "state-dir", "msg": "required"})
+			}
+
+			loc, err := time.LoadLocation("America/Halifax")
+			if err != nil {
+				return errutil.With(err)
+			}
+
+			if rootConfig.ri.len() == 0 {
+				// Nothing to recompute, such as when the index was lost, so
+				// seed it with every counter and width as of yesterday.
+				rc := counterbaseRecordser{qu: rootConfig.qu, ccd: rootConfig.ccd, idx: rootConfig.ri}
+				if _, err := rc.holders(ctx, time.Now().In(loc).AddDate(0, 0, -1)); err != nil {
+					return errutil.With(err)
+				}
+			} else if err := rootConfig.ri.rebuild(ctx, rootConfig.qu, loc); err != nil {
+				return errutil.With(err)
+			}
+			if err := rootConfig.ri.save(); err != nil {
+				return errutil.With(err)
+			}
+
+			fmt.Println("rebuilt", rootConfig.ri.len(), "record index entries")
+			return nil
+		},
+	}
+
+	var (
+		fs   = flag.NewFlagSet("bikehfx-post records", flag.ExitOnError)
+		asOf = fs.String("as-of", time.Now().AddDate(0, 0, -1).Format("20060102"), "inclusive latest day to consider, in YYYYMMDD form")
+	)
+
+	return &ffcli.Command{
+		Name:        "records",
+		ShortUsage:  "bikehfx-post records [<subcommand>]",
+		ShortHelp:   "print current record holders",
+		FlagSet:     fs,
+		Subcommands: []*ffcli.Command{rebuildCmd},
+		Exec: func(ctx context.Context, args []string) error {
+			loc, err := time.LoadLocation("America/Halifax")
+			if err != nil {
+				return errutil.With(err)
+			}
+
+			asOfDay, err := time.ParseInLocation("20060102", *asOf, loc)
+			if err != nil {
+				return errutil.With(err)
+			}
+
+			rc := counterbaseRecordser{qu: rootConfig.qu, ccd: rootConfig.ccd, idx: rootConfig.ri}
+			holders, err := rc.holders(ctx, asOfDay)
+			if err != nil {
+				return errutil.With(err)
+			}
+			if rootConfig.ri != nil {
+				if err := rootConfig.ri.save(); err != nil {
+					return errutil.With(err)
+				}
+			}
+
+			return writeRecordHolders(os.Stdout, asOfDay, holders)
+		},
+	}
+}
+
 type recordWidth int
 
 const (
@@ -26,15 +111,19 @@ const (
 )
 
 type recordser interface {
-	records(ctx context.Context, before time.Time, currentValues []counterSeries, width recordWidth) (map[string]recordKind, error)
+	// records returns the kind of record each counter, or "sum", sets for
+	// the period starting at before, and journal entries for them to add
+	// once they are posted.
+	records(ctx context.Context, before time.Time, currentValues []counterSeries, width recordWidth) (map[string]recordKind, []recordJournalEntry, error)
 }
 
 type counterbaseRecordser struct {
 	qu  Querier
 	ccd cyclingCounterDirectory
+	idx *recordIndex // optional
 }
 
-func (r counterbaseRecordser) records(ctx context.Context, before time.Time, currentValues []counterSeries, width recordWidth) (map[string]recordKind, error) {
+func (r counterbaseRecordser) records(ctx context.Context, before time.Time, currentValues []counterSeries, width recordWidth) (map[string]recordKind, []recordJournalEntry, error) {
 	boy := time.Date(before.Year(), 1, 1, 0, 0, 0, 0, before.Location())
 
 	recordRanges := map[recordKind]timeRange{
@@ -46,6 +135,7 @@ func (r counterbaseRecordser) records(ctx context.Context, before time.Time, cur
 		recordRangeOrder = append(recordRangeOrder, recordKindYTD)
 	}
 	records := make(map[string]recordKind)
+	var broken []recordJournalEntry
 
 	for _, c := range currentValues {
 		if len(c.series) == 0 {
@@ -56,48 +146,234 @@ func (r counterbaseRecordser) records(ctx context.Context, before time.Time, cur
 				break
 			}
 			rr := recordRanges[rk]
-			is, err := isRecordForCounters(ctx, r.qu, []directory.Counter{c.counter}, width, rr, c.series[0].val)
+			m, err := r.recordMax(ctx, []directory.Counter{c.counter}, width, rk, rr)
 			if err != nil {
-				return nil, errutil.With(err)
+				return nil, nil, errutil.With(err)
 			}
-			if is {
+			if m.beatenBy(c.series[0].val) {
 				records[c.counter.ID] = rk
+				broken = append(broken, newRecordJournalEntry(before, width, rk, c.counter.ID, counterName(c.counter), c.series[0].val, m))
 			}
 		}
 	}
 
+	// Compare the sum like-for-like: the historical max covers only the
+	// counters contributing to today's sum.
+	var sumCounters []directory.Counter
 	var csSum int
 	for _, c := range currentValues {
-		if len(c.series) == 0 {
+		if !countsTowardSum(c) {
 			continue
 		}
+		sumCounters = append(sumCounters, c.counter)
 		csSum += c.series[0].val
 	}
 
+	// Nor does it look back before every one of those counters was counting,
+	// such as before a new counter was installed.
+	sumSince := sumDataSince(sumCounters, width, before)
+
 	for _, rk := range recordRangeOrder {
-		if _, ok := records["sum"]; ok {
+		if _, ok := records["sum"]; ok || len(sumCounters) == 0 {
 			break
 		}
 		rr := recordRanges[rk]
-
-		counters, err := r.ccd.counters(ctx, rr)
-		if err != nil {
-			return nil, errutil.With(err)
+		if sumSince.After(rr.begin) {
+			rr.begin = sumSince
+		}
+		if !rr.begin.Before(rr.end) {
+			// No history to compare against yet.
+			continue
 		}
 
-		is, err := isRecordForCounters(ctx, r.qu, counters, width, rr, csSum)
+		m, err := r.recordMax(ctx, sumCounters, width, rk, rr)
 		if err != nil {
-			return nil, errutil.With(err)
+			return nil, nil, errutil.With(err)
 		}
-		if is {
+		if m.beatenBy(csSum) {
 			records["sum"] = rk
+			broken = append(broken, newRecordJournalEntry(before, width, rk, "sum", recordHolderCityWide, csSum, m))
+		}
+	}
+
+	if r.idx != nil {
+		if err := r.idx.save(); err != nil {
+			return nil, nil, errutil.With(err)
+		}
+	}
+
+	return records, broken, nil
+}
+
+const recordHolderCityWide = "City-wide"
+
+type recordHolder struct {
+	width   recordWidth
+	kind    recordKind
+	counter string
+	max     recordMax
+}
+
+// holders returns the record for each width and kind over complete periods
+// through asOfDay, city-wide and for each counter.
+func (r counterbaseRecordser) holders(ctx context.Context, asOfDay time.Time) ([]recordHolder, error) {
+	after := asOfDay.AddDate(0, 0, 1)
+	boy := time.Date(asOfDay.Year(), 1, 1, 0, 0, 0, 0, asOfDay.Location())
+
+	var out []recordHolder
+	for _, width := range []recordWidth{recordWidthDay, recordWidthWeek, recordWidthMonth, recordWidthYear} {
+		end := recordPeriodStart(width, after)
+
+		lookbacks := map[recordKind]timeRange{recordKindAllTime: {end: end}}
+		if width != recordWidthYear && boy.Before(end) {
+			lookbacks[recordKindYTD] = timeRange{begin: boy, end: end}
+		}
+
+		for _, kind := range []recordKind{recordKindAllTime, recordKindYTD} {
+			lookback, ok := lookbacks[kind]
+			if !ok {
+				continue
+			}
+
+			counters, err := r.ccd.counters(ctx, lookback)
+			if err != nil {
+				return nil, errutil.With(err)
+			}
+			if len(counters) == 0 {
+				continue
+			}
+
+			m, err := r.recordMax(ctx, counters, width, kind, lookback)
+			if err != nil {
+				return nil, errutil.With(err)
+			}
+			if m.found {
+				out = append(out, recordHolder{width: width, kind: kind, counter: recordHolderCityWide, max: m})
+			}
+
+			slices.SortFunc(counters, func(a, b directory.Counter) int {
+				return cmp.Compare(counterName(a), counterName(b))
+			})
+			for _, c := range counters {
+				m, err := r.recordMax(ctx, []directory.Counter{c}, width, kind, lookback)
+				if err != nil {
+					return nil, errutil.With(err)
+				}
+				if m.found {
+					out = append(out, recordHolder{width: width, kind: kind, counter: counterName(c), max: m})
+				}
+			}
 		}
 	}
 
-	return records, nil
+	return out, nil
+}
+
+func writeRecordHolders(w io.Writer, asOfDay time.Time, holders []recordHolder) error {
+	p := message.NewPrinter(language.English)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	p.Fprintf(tw, "Records through %v\n\n", asOfDay.Format("2006-01-02"))
+	p.Fprintf(tw, "WIDTH\tKIND\tCOUNTER\tCOUNT\tSET\n")
+	for _, h := range holders {
+		p.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\n", recordWidthName(h.width), recordKindName(h.kind), h.counter, h.max.val, h.max.at)
+	}
+	if err := tw.Flush(); err != nil {
+		return errutil.With(err)
+	}
+	return nil
+}
+
+// recordPeriodStart returns the start of the width's period containing t.
+func recordPeriodStart(width recordWidth, t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	switch width {
+	case recordWidthWeek:
+		return day.AddDate(0, 0, -int(day.Weekday()))
+	case recordWidthMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	case recordWidthYear:
+		return time.Date(t.Year(), 1, 1, 0, 0, 0, 0, t.Location())
+	}
+	return day
+}
+
+// sumDataSince returns the start of the first width period that all of
+// counters were in service for, as of before, or the zero time if that is
+// unknown.
+func sumDataSince(counters []directory.Counter, width recordWidth, before time.Time) time.Time {
+	var since time.Time
+	for _, c := range counters {
+		var start time.Time
+		for _, sr := range c.ServiceRanges {
+			if !sr.Start.After(before) && sr.Start.After(start) {
+				start = sr.Start.Time
+			}
+		}
+		if start.After(since) {
+			since = start
+		}
+	}
+	if since.IsZero() {
+		return since
+	}
+
+	// Service dates are calendar dates.
+	since = time.Date(since.Year(), since.Month(), since.Day(), 0, 0, 0, 0, before.Location())
+	p := recordPeriodStart(width, since)
+	if p.Before(since) {
+		// Skip the partial period the counter started in.
+		p = recordPeriodStart(width, recordPeriodEnd(width, p))
+	}
+	return p
+}
+
+// recordPeriodEnd returns the exclusive end of the width's period starting at
+// start.
+func recordPeriodEnd(width recordWidth, start time.Time) time.Time {
+	switch width {
+	case recordWidthWeek:
+		return start.AddDate(0, 0, 7)
+	case recordWidthMonth:
+		return start.AddDate(0, 1, 0)
+	case recordWidthYear:
+		return start.AddDate(1, 0, 0)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// countsTowardSum reports whether c is part of the counter set used for sum
+// records.
+func countsTowardSum(c counterSeries) bool {
+	return len(c.series) > 0 && c.status != counterDataStatusMissing
 }
 
-func isRecordForCounters(ctx context.Context, qu Querier, counters []directory.Counter, width recordWidth, lookback timeRange, val int) (bool, error) {
+func (r counterbaseRecordser) recordMax(ctx context.Context, counters []directory.Counter, width recordWidth, kind recordKind, lookback timeRange) (recordMax, error) {
+	if r.idx == nil {
+		return queryRecordMax(ctx, r.qu, counters, width, lookback)
+	}
+	return r.idx.max(ctx, r.qu, counters, width, kind, lookback)
+}
+
+// recordMax is the highest value over a lookback for a set of counters.
+type recordMax struct {
+	val   int
+	at    string // start of the period holding val, in YYYY-MM-DD form
+	found bool
+}
+
+func (m recordMax) beatenBy(val int) bool {
+	return !m.found || m.val < val
+}
+
+func (m recordMax) merge(o recordMax) recordMax {
+	if !o.found || (m.found && m.val >= o.val) {
+		return m
+	}
+	return o
+}
+
+func queryRecordMax(ctx context.Context, qu Querier, counters []directory.Counter, width recordWidth, lookback timeRange) (recordMax, error) {
 	var quotedCounterIDs []string
 	for _, c := range counters {
 		quotedCounterIDs = append(quotedCounterIDs, "'"+c.ID+"'")
@@ -113,7 +389,7 @@ func isRecordForCounters(ctx context.Context, qu Querier, counters []directory.C
 	case recordWidthYear:
 		modifiers = append(modifiers, "'start of year'")
 	default:
-		return false, errutil.New(errutil.Tags{"width": width})
+		return recordMax{}, errutil.New(errutil.Tags{"width": width})
 	}
 
 	q := `select cast(strftime('%s', date(time,'unixepoch','localtime'`
@@ -134,13 +410,14 @@ func isRecordForCounters(ctx context.Context, qu Querier, counters []directory.C
 
 	pts, err := qu.Query(ctx, q)
 	if err != nil {
-		return false, errutil.With(err)
+		return recordMax{}, errutil.With(err)
 	}
 	if len(pts) == 0 {
-		return true, nil
+		return recordMax{}, nil
 	}
 
-	return int(pts[0].Value) < val, nil
+	// The period start is a local date encoded as a UTC midnight.
+	return recordMax{val: int(pts[0].Value), at: pts[0].Time.UTC().Format("2006-01-02"), found: true}, nil
 }
 
 func recordSymbol(k recordKind) string {
@@ -153,6 +430,30 @@ func recordSymbol(k recordKind) string {
 	return ""
 }
 
+func recordWidthName(w recordWidth) string {
+	switch w {
+	case recordWidthDay:
+		return "day"
+	case recordWidthWeek:
+		return "week"
+	case recordWidthMonth:
+		return "month"
+	case recordWidthYear:
+		return "year"
+	}
+	return ""
+}
+
+func recordKindName(k recordKind) string {
+	switch k {
+	case recordKindAllTime:
+		return "all-time"
+	case recordKindYTD:
+		return "year-to-date"
+	}
+	return ""
+}
+
 func recordNote(k recordKind) string {
 	switch k {
 	case recordKindAllTime:
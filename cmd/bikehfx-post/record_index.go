@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/danp/counterbase/directory"
+	"github.com/graxinc/errutil"
+)
+
+// recordIndex persists the maximum period values used for record checks so
+// each run only has to query the data added since the previous one.
+//
+// Entries are keyed by width, counter set and lookback begin. Through is the
+// exclusive end of the data an entry covers and must fall on a period
+// boundary for its width, which holds for the period starts posts pass in.
+// On save, entries a run did not ask for are dropped if it asked for others
+// of the same width and kind.
+type recordIndex struct {
+	path string
+
+	mu      sync.Mutex
+	entries []recordIndexEntry
+}
+
+type recordIndexEntry struct {
+	Width    recordWidth `json:"width"`
+	Kind     recordKind  `json:"kind"`
+	Counters []string    `json:"counters"`
+	Begin    string      `json:"begin,omitempty"`
+	Through  string      `json:"through"`
+	Max      int         `json:"max"`
+	MaxAt    string      `json:"max_at,omitempty"`
+	Found    bool        `json:"found"`
+
+	requested bool // asked for or updated this run
+
+}
+
+// recordIndexLateDays is how far back of an entry's through date data may
+// still change. Later corrections need a rebuild.
+const recordIndexLateDays = 7
+
+type recordIndexFile struct {
+	Entries []recordIndexEntry `json:"entries"`
+}
+
+func loadRecordIndex(path string) (*recordIndex, error) {
+	idx := &recordIndex{path: path}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return idx, nil
+	}
+	if err != nil {
+		return nil, errutil.With(err)
+	}
+
+	var f recordIndexFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, errutil.With(err)
+	}
+	idx.entries = f.Entries
+
+	return idx, nil
+}
+
+func (x *recordIndex) save() error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	x.prune()
+	b, err := json.MarshalIndent(recordIndexFile{Entries: x.entries}, "", "  ")
+	if err != nil {
+		return errutil.With(err)
+	}
+	return writeFileAtomic(x.path, b)
+}
+
+func (x *recordIndex) max(ctx context.Context, qu Querier, counters []directory.Counter, width recordWidth, kind recordKind, lookback timeRange) (recordMax, error) {
+	ids := recordIndexCounterIDs(counters)
+	begin := formatDate(lookback.begin)
+	through := lookback.end.Format("2006-01-02")
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	i := slices.IndexFunc(x.entries, func(e recordIndexEntry) bool {
+		return e.Width == width && e.Kind == kind && slices.Equal(e.Counters, ids)
+	})
+	if i >= 0 && x.entries[i].Begin == begin {
+		e := x.entries[i]
+		switch {
+		case e.Through == through:
+			x.entries[i].requested = true
+			return e.recordMax(), nil
+		case e.Through < through:
+			eThrough, err := time.ParseInLocation("2006-01-02", e.Through, lookback.end.Location())
+			if err != nil {
+				return recordMax{}, errutil.With(err)
+			}
+			// Re-query the last stretch the entry covers too, so counts that
+			// arrived late for it are picked up.
+			from := recordPeriodStart(width, eThrough.AddDate(0, 0, -recordIndexLateDays))
+			if from.Before(lookback.begin) {
+				from = lookback.begin
+			}
+			m, err := queryRecordMax(ctx, qu, counters, width, timeRange{begin: from, end: lookback.end})
+			if err != nil {
+				return recordMax{}, errutil.With(err)
+			}
+			switch {
+			case !e.Found || e.MaxAt < formatDate(from):
+				m = e.recordMax().merge(m)
+			case m.found && m.val >= e.Max:
+				// The stored max was re-queried and still holds or was beaten
+				// within the window.
+			default:
+				// The stored max was re-queried and dropped, so whatever now
+				// holds the record may be outside the window.
+				m, err = queryRecordMax(ctx, qu, counters, width, lookback)
+				if err != nil {
+					return recordMax{}, errutil.With(err)
+				}
+			}
+			x.entries[i] = newRecordIndexEntry(width, kind, ids, begin, through, m)
+			return m, nil
+		default:
+			// The index is ahead of this lookback, such as during a backfill.
+			// Answer directly and keep the newer entry.
+			x.entries[i].requested = true
+			return queryRecordMax(ctx, qu, counters, width, lookback)
+		}
+	}
+
+	m, err := queryRecordMax(ctx, qu, counters, width, lookback)
+	if err != nil {
+		return recordMax{}, errutil.With(err)
+	}
+	e := newRecordIndexEntry(width, kind, ids, begin, through, m)
+	if i >= 0 {
+		// Begin moved, such as a new year for year-to-date records.
+		x.entries[i] = e
+	} else {
+		x.entries = append(x.entries, e)
+	}
+	return m, nil
+}
+
+// prune drops entries that were not requested this run for a width and kind
+// that was, such as for a counter set that no longer occurs. x.mu must be
+// held.
+func (x *recordIndex) prune() {
+	type widthKind struct {
+		width recordWidth
+		kind  recordKind
+	}
+	requested := make(map[widthKind]bool)
+	for _, e := range x.entries {
+		if e.requested {
+			requested[widthKind{e.Width, e.Kind}] = true
+		}
+	}
+	x.entries = slices.DeleteFunc(x.entries, func(e recordIndexEntry) bool {
+		return !e.requested && requested[widthKind{e.Width, e.Kind}]
+	})
+}
+
+func (x *recordIndex) len() int {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return len(x.entries)
+}
+
+// rebuild recomputes every entry from scratch over its stored lookback.
+func (x *recordIndex) rebuild(ctx context.Context, qu Querier, loc *time.Location) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	for i, e := range x.entries {
+		var lookback timeRange
+		if e.Begin != "" {
+			begin, err := time.ParseInLocation("2006-01-02", e.Begin, loc)
+			if err != nil {
+				return errutil.With(err)
+			}
+			lookback.begin = begin
+		}
+		end, err := time.ParseInLocation("2006-01-02", e.Through, loc)
+		if err != nil {
+			return errutil.With(err)
+		}
+		lookback.end = end
+
+		counters := make([]directory.Counter, 0, len(e.Counters))
+		for _, id := range e.Counters {
+			counters = append(counters, directory.Counter{ID: id})
+		}
+
+		m, err := queryRecordMax(ctx, qu, counters, e.Width, lookback)
+		if err != nil {
+			return errutil.With(err)
+		}
+		x.entries[i] = newRecordIndexEntry(e.Width, e.Kind, e.Counters, e.Begin, e.Through, m)
+	}
+
+	return nil
+}
+
+func newRecordIndexEntry(width recordWidth, kind recordKind, ids []string, begin, through string, m recordMax) recordIndexEntry {
+	return recordIndexEntry{
+		Width:    width,
+		Kind:     kind,
+		Counters: ids,
+		Begin:    begin,
+		Through:  through,
+		Max:      m.val,
+		MaxAt:    m.at,
+		Found:    m.found,
+
+		requested: true,
+	}
+}
+
+func (e recordIndexEntry) recordMax() recordMax {
+	return recordMax{val: e.Max, at: e.MaxAt, found: e.Found}
+}
+
+func recordIndexCounterIDs(counters []directory.Counter) []string {
+	ids := make([]string, 0, len(counters))
+	for _, c := range counters {
+		ids = append(ids, c.ID)
+	}
+	slices.Sort(ids)
+	return slices.Compact(ids)
+}
+
+func writeFileAtomic(path string, b []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return errutil.With(err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return errutil.With(err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return errutil.With(err)
+	}
+	if err := tmp.Close(); err != nil {
+		return errutil.With(err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return errutil.With(err)
+	}
+	return nil
+}
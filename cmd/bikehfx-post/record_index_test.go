@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danp/counterbase/directory"
+	"github.com/danp/counterbase/query"
+)
+
+type recordingQuerier struct {
+	queries []string
+	points  []query.Point
+
+	// next, if set, answers queries in turn before points does.
+	next [][]query.Point
+}
+
+func (q *recordingQuerier) Query(_ context.Context, qq string) ([]query.Point, error) {
+	q.queries = append(q.queries, qq)
+	if len(q.next) > 0 {
+		pts := q.next[0]
+		q.next = q.next[1:]
+		return pts, nil
+	}
+	return q.points, nil
+}
+
+func TestRecordIndexIncremental(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	loc := time.UTC
+	counters := []directory.Counter{{ID: "b"}, {ID: "a"}}
+	path := filepath.Join(t.TempDir(), "records.json")
+
+	idx, err := loadRecordIndex(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	qu := &recordingQuerier{points: []query.Point{{Time: time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC), Value: 100}}}
+	lookback := timeRange{end: time.Date(2023, 7, 21, 0, 0, 0, 0, loc)}
+
+	m, err := idx.max(ctx, qu, counters, recordWidthDay, recordKindAllTime, lookback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (recordMax{val: 100, at: "2023-06-01", found: true}); m != want {
+		t.Fatalf("max = %+v, want %+v", m, want)
+	}
+	if len(qu.queries) != 1 || strings.Contains(qu.queries[0], ">=") {
+		t.Fatalf("expected one full-history query, got %q", qu.queries)
+	}
+
+	if err := idx.save(); err != nil {
+		t.Fatal(err)
+	}
+	idx, err = loadRecordIndex(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	qu.queries = nil
+	if _, err := idx.max(ctx, qu, counters, recordWidthDay, recordKindAllTime, lookback); err != nil {
+		t.Fatal(err)
+	}
+	if len(qu.queries) != 0 {
+		t.Fatalf("expected no queries for an up-to-date entry, got %q", qu.queries)
+	}
+
+	qu.queries = nil
+	qu.points = []query.Point{{Time: time.Date(2023, 7, 21, 0, 0, 0, 0, time.UTC), Value: 150}}
+	m, err = idx.max(ctx, qu, counters, recordWidthDay, recordKindAllTime, timeRange{end: lookback.end.AddDate(0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (recordMax{val: 150, at: "2023-07-21", found: true}); m != want {
+		t.Fatalf("max = %+v, want %+v", m, want)
+	}
+	// The incremental query reaches back over the last week for late data.
+	if len(qu.queries) != 1 || !strings.Contains(qu.queries[0], ">= '2023-07-14'") {
+		t.Fatalf("expected one incremental query, got %q", qu.queries)
+	}
+
+	qu.queries = nil
+	if _, err := idx.max(ctx, qu, counters[:1], recordWidthDay, recordKindAllTime, timeRange{end: lookback.end.AddDate(0, 0, 1)}); err != nil {
+		t.Fatal(err)
+	}
+	if len(qu.queries) != 1 || strings.Contains(qu.queries[0], ">=") {
+		t.Fatalf("expected full recomputation for a different counter set, got %q", qu.queries)
+	}
+}
+
+func TestRecordIndexCountDropped(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	counters := []directory.Counter{{ID: "a"}}
+	lookback := timeRange{end: time.Date(2023, 7, 21, 0, 0, 0, 0, time.UTC)}
+	next := timeRange{end: lookback.end.AddDate(0, 0, 1)}
+
+	for _, tt := range []struct {
+		name    string
+		stored  time.Time
+		window  []query.Point
+		full    []query.Point
+		want    recordMax
+		queries int
+	}{
+		{
+			name:    "corrected down",
+			stored:  time.Date(2023, 7, 18, 0, 0, 0, 0, time.UTC),
+			window:  []query.Point{{Time: time.Date(2023, 7, 18, 0, 0, 0, 0, time.UTC), Value: 120}},
+			full:    []query.Point{{Time: time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC), Value: 150}},
+			want:    recordMax{val: 150, at: "2023-06-01", found: true},
+			queries: 2,
+		},
+		{
+			name:    "removed",
+			stored:  time.Date(2023, 7, 18, 0, 0, 0, 0, time.UTC),
+			full:    []query.Point{{Time: time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC), Value: 150}},
+			want:    recordMax{val: 150, at: "2023-06-01", found: true},
+			queries: 2,
+		},
+		{
+			name:    "still holds",
+			stored:  time.Date(2023, 7, 18, 0, 0, 0, 0, time.UTC),
+			window:  []query.Point{{Time: time.Date(2023, 7, 18, 0, 0, 0, 0, time.UTC), Value: 200}},
+			want:    recordMax{val: 200, at: "2023-07-18", found: true},
+			queries: 1,
+		},
+		{
+			name:    "outside window",
+			stored:  time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC),
+			window:  []query.Point{{Time: time.Date(2023, 7, 18, 0, 0, 0, 0, time.UTC), Value: 120}},
+			want:    recordMax{val: 200, at: "2023-06-01", found: true},
+			queries: 1,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			idx, err := loadRecordIndex(filepath.Join(t.TempDir(), "records.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			qu := &recordingQuerier{points: []query.Point{{Time: tt.stored, Value: 200}}}
+			if _, err := idx.max(ctx, qu, counters, recordWidthDay, recordKindAllTime, lookback); err != nil {
+				t.Fatal(err)
+			}
+
+			qu.queries = nil
+			qu.points = tt.full
+			qu.next = [][]query.Point{tt.window}
+			m, err := idx.max(ctx, qu, counters, recordWidthDay, recordKindAllTime, next)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m != tt.want {
+				t.Errorf("max = %+v, want %+v", m, tt.want)
+			}
+			if len(qu.queries) != tt.queries {
+				t.Errorf("got %d queries, want %d: %q", len(qu.queries), tt.queries, qu.queries)
+			}
+			if tt.queries > 1 && strings.Contains(qu.queries[1], ">=") {
+				t.Errorf("expected a full recomputation, got %q", qu.queries[1])
+			}
+		})
+	}
+}
+
+func TestRecordIndexPrune(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "records.json")
+	idx, err := loadRecordIndex(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	qu := &recordingQuerier{}
+	lookback := timeRange{end: time.Date(2023, 7, 21, 0, 0, 0, 0, time.UTC)}
+	for _, counters := range [][]directory.Counter{{{ID: "a"}}, {{ID: "b"}}} {
+		if _, err := idx.max(ctx, qu, counters, recordWidthDay, recordKindAllTime, lookback); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := idx.max(ctx, qu, []directory.Counter{{ID: "a"}}, recordWidthWeek, recordKindAllTime, lookback); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A later run asks only for counter a's daily record.
+	idx, err = loadRecordIndex(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := idx.max(ctx, qu, []directory.Counter{{ID: "a"}}, recordWidthDay, recordKindAllTime, lookback); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	idx, err = loadRecordIndex(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range idx.entries {
+		got = append(got, recordWidthName(e.Width)+" "+strings.Join(e.Counters, ","))
+	}
+	// Counter b's daily entry is gone; the weekly one was not asked about.
+	if want := []string{"day a", "week a"}; !slices.Equal(got, want) {
+		t.Errorf("entries = %q, want %q", got, want)
+	}
+}
+
+func TestRecordIndexLateDataOverlap(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	counters := []directory.Counter{{ID: "a"}}
+	idx, err := loadRecordIndex(filepath.Join(t.TempDir(), "records.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	qu := &recordingQuerier{}
+
+	for _, tt := range []struct {
+		width    recordWidth
+		kind     recordKind
+		lookback timeRange
+		next     time.Time
+		want     string
+	}{
+		// Back to the start of the week a week before.
+		{recordWidthWeek, recordKindAllTime, timeRange{end: time.Date(2023, 7, 23, 0, 0, 0, 0, time.UTC)}, time.Date(2023, 7, 30, 0, 0, 0, 0, time.UTC), ">= '2023-07-16'"},
+		// But not before the lookback begins.
+		{recordWidthDay, recordKindYTD, timeRange{begin: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), end: time.Date(2023, 1, 3, 0, 0, 0, 0, time.UTC)}, time.Date(2023, 1, 4, 0, 0, 0, 0, time.UTC), ">= '2023-01-01'"},
+	} {
+		if _, err := idx.max(ctx, qu, counters, tt.width, tt.kind, tt.lookback); err != nil {
+			t.Fatal(err)
+		}
+		qu.queries = nil
+		if _, err := idx.max(ctx, qu, counters, tt.width, tt.kind, timeRange{begin: tt.lookback.begin, end: tt.next}); err != nil {
+			t.Fatal(err)
+		}
+		if len(qu.queries) != 1 || !strings.Contains(qu.queries[0], tt.want) {
+			t.Errorf("width %v kind %v: got %q, want one query with %s", tt.width, tt.kind, qu.queries, tt.want)
+		}
+	}
+}
+
+func TestRecordIndexSeededByHolders(t *testing.T) {
+	t.Parallel()
+
+	idx, err := loadRecordIndex(filepath.Join(t.TempDir(), "records.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	since := directory.SD(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	dir := staticDirectory{C: []directory.Counter{
+		{ID: "a", Mode: "cycling", ServiceRanges: []directory.ServiceRange{{Start: since}}},
+		{ID: "b", Mode: "cycling", ServiceRanges: []directory.ServiceRange{{Start: since}}},
+	}}
+	rc := counterbaseRecordser{qu: &recordingQuerier{}, ccd: cyclingCounterDirectoryWrapper{dir: dir}, idx: idx}
+	if _, err := rc.holders(context.Background(), time.Date(2023, 7, 21, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+
+	// City-wide and each counter, for 4 widths, all-time and year-to-date
+	// except for years.
+	if got, want := idx.len(), 3*(4+3); got != want {
+		t.Errorf("seeded %d entries, want %d", got, want)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/graxinc/errutil"
+)
+
+// recordJournal is an append-only log of records broken during posting runs,
+// one JSON object per line.
+type recordJournal struct {
+	path string
+
+	mu sync.Mutex
+}
+
+type recordJournalEntry struct {
+	RecordedAt  time.Time   `json:"recorded_at"`
+	Width       recordWidth `json:"width"`
+	Kind        recordKind  `json:"kind"`
+	Period      string      `json:"period"`
+	CounterID   string      `json:"counter_id"`
+	CounterName string      `json:"counter_name"`
+	Value       int         `json:"value"`
+	Previous    int         `json:"previous,omitempty"`
+	PreviousAt  string      `json:"previous_at,omitempty"`
+}
+
+func newRecordJournalEntry(period time.Time, width recordWidth, kind recordKind, counterID, counterName string, value int, previous recordMax) recordJournalEntry {
+	return recordJournalEntry{
+		RecordedAt:  time.Now().UTC(),
+		Width:       width,
+		Kind:        kind,
+		Period:      period.Format("2006-01-02"),
+		CounterID:   counterID,
+		CounterName: counterName,
+		Value:       value,
+		Previous:    previous.val,
+		PreviousAt:  previous.at,
+	}
+}
+
+func (e recordJournalEntry) sameRecord(o recordJournalEntry) bool {
+	return e.Width == o.Width && e.Kind == o.Kind && e.Period == o.Period && e.CounterID == o.CounterID && e.Value == o.Value
+}
+
+func (j *recordJournal) entries() ([]recordJournalEntry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.read()
+}
+
+func (j *recordJournal) read() ([]recordJournalEntry, error) {
+	f, err := os.Open(j.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errutil.With(err)
+	}
+	defer f.Close()
+
+	var out []recordJournalEntry
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var e recordJournalEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return nil, errutil.With(err)
+		}
+		out = append(out, e)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, errutil.With(err)
+	}
+	return out, nil
+}
+
+// append adds entries, skipping any already journaled by an earlier run for
+// the same period.
+func (j *recordJournal) append(entries ...recordJournalEntry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	existing, err := j.read()
+	if err != nil {
+		return errutil.With(err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(j.path), 0o755); err != nil {
+		return errutil.With(err)
+	}
+	f, err := os.OpenFile(j.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return errutil.With(err)
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	for _, e := range entries {
+		if slices.ContainsFunc(existing, e.sameRecord) {
+			continue
+		}
+		if err := enc.Encode(e); err != nil {
+			return errutil.With(err)
+		}
+		existing = append(existing, e)
+	}
+
+	if err := f.Close(); err != nil {
+		return errutil.With(err)
+	}
+	return nil
+}
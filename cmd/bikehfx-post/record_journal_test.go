@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecordJournalAppendSkipsRepeats(t *testing.T) {
+	t.Parallel()
+
+	j := &recordJournal{path: filepath.Join(t.TempDir(), "records-journal.jsonl")}
+	day := time.Date(2023, 7, 21, 0, 0, 0, 0, time.UTC)
+
+	first := newRecordJournalEntry(day, recordWidthDay, recordKindAllTime, "a", "Apple", 123, recordMax{val: 100, at: "2023-06-01", found: true})
+	if err := j.append(first); err != nil {
+		t.Fatal(err)
+	}
+
+	// A rerun for the same day journals the same record again.
+	second := newRecordJournalEntry(day, recordWidthDay, recordKindAllTime, "a", "Apple", 123, recordMax{val: 100, at: "2023-06-01", found: true})
+	sum := newRecordJournalEntry(day, recordWidthDay, recordKindYTD, "sum", recordHolderCityWide, 456, recordMax{})
+	if err := j.append(second, sum); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := j.entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d entries, want 2: %+v", len(got), got)
+	}
+	if got[0].CounterID != "a" || got[0].Previous != 100 || got[0].PreviousAt != "2023-06-01" {
+		t.Errorf("first entry = %+v", got[0])
+	}
+	if got[1].CounterID != "sum" || got[1].Kind != recordKindYTD || got[1].Value != 456 {
+		t.Errorf("second entry = %+v", got[1])
+	}
+}
+
+func TestPostThreadSummaryJournalsPostedRecords(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	j := &recordJournal{path: filepath.Join(t.TempDir(), "records-journal.jsonl")}
+	day := time.Date(2023, 7, 21, 0, 0, 0, 0, time.UTC)
+	records := []recordJournalEntry{newRecordJournalEntry(day, recordWidthDay, recordKindAllTime, "a", "Apple", 123, recordMax{})}
+	key := threadKey{kind: "daily", period: "20230721"}
+	posts := []post{{text: "one"}}
+
+	// Nothing is journaled while posting fails.
+	fp := &failingPoster{failAt: 1}
+	if err := postThreadSummary(ctx, posterThreader{p: fp, name: "fake"}, j, key, posts, records); err == nil {
+		t.Fatal("got no error from a failing poster")
+	}
+	if got, err := j.entries(); err != nil || len(got) != 0 {
+		t.Fatalf("journal after failure = %+v, %v, want empty", got, err)
+	}
+
+	if err := postThreadSummary(ctx, posterThreader{p: fp, name: "fake"}, j, key, posts, records); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := j.entries(); err != nil || len(got) != 1 || got[0].CounterID != "a" {
+		t.Fatalf("journal after posting = %+v, %v, want the record", got, err)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danp/counterbase/directory"
+	"github.com/danp/counterbase/query"
+)
+
+func TestRecordsSumComparesReportingCounters(t *testing.T) {
+	t.Parallel()
+
+	day := time.Date(2023, 7, 21, 0, 0, 0, 0, time.UTC)
+	dayRange := newTimeRangeDate(day, 0, 0, 1)
+
+	cs := []counterSeries{
+		{counter: directory.Counter{ID: "a"}, series: []timeRangeValue{{tr: dayRange, val: 10}}},
+		{counter: directory.Counter{ID: "b"}, series: []timeRangeValue{{tr: dayRange, val: 20}}},
+		{counter: directory.Counter{ID: "c"}, series: []timeRangeValue{{tr: dayRange}}, status: counterDataStatusMissing},
+		{counter: directory.Counter{ID: "d"}},
+	}
+
+	qu := &recordingQuerier{}
+	rc := counterbaseRecordser{qu: qu}
+	records, broken, err := rc.records(context.Background(), day, cs, recordWidthDay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if records["sum"] != recordKindAllTime {
+		t.Fatalf("records = %v, want all-time sum record", records)
+	}
+	if i := slices.IndexFunc(broken, func(e recordJournalEntry) bool { return e.CounterID == "sum" }); i < 0 || broken[i].Value != 30 {
+		t.Errorf("broken = %+v, want a sum entry of 30", broken)
+	}
+
+	var sumQueries int
+	for _, q := range qu.queries {
+		if !strings.Contains(q, "counter_id in ('a','") {
+			continue // single counter record check
+		}
+		sumQueries++
+		if !strings.Contains(q, "counter_id in ('a','b')") {
+			t.Errorf("sum query not limited to reporting counters: %v", q)
+		}
+	}
+	if sumQueries == 0 {
+		t.Fatalf("no sum query over the reporting counters in %q", qu.queries)
+	}
+}
+
+func TestRecordsSumLooksBackOnlyWhileAllCountersCounted(t *testing.T) {
+	t.Parallel()
+
+	day := time.Date(2023, 7, 21, 0, 0, 0, 0, time.UTC)
+	dayRange := newTimeRangeDate(day, 0, 0, 1)
+	old := []directory.ServiceRange{{Start: directory.SD(time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC))}}
+
+	for _, tt := range []struct {
+		name      string
+		installed time.Time
+		width     recordWidth
+		want      []string // sum query lookback conditions, all-time then year-to-date
+	}{
+		{"day", time.Date(2023, 7, 10, 0, 0, 0, 0, time.UTC), recordWidthDay, []string{">= '2023-07-10'", ">= '2023-07-10'"}},
+		{"week skips partial week", time.Date(2023, 7, 4, 0, 0, 0, 0, time.UTC), recordWidthWeek, []string{">= '2023-07-09'", ">= '2023-07-09'"}},
+		{"before this year", time.Date(2022, 5, 1, 0, 0, 0, 0, time.UTC), recordWidthDay, []string{">= '2022-05-01'", ">= '2023-01-01'"}},
+		{"installed today", day, recordWidthDay, nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cs := []counterSeries{
+				{counter: directory.Counter{ID: "a", ServiceRanges: old}, series: []timeRangeValue{{tr: dayRange, val: 10}}},
+				{counter: directory.Counter{ID: "b", ServiceRanges: []directory.ServiceRange{{Start: directory.SD(tt.installed)}}}, series: []timeRangeValue{{tr: dayRange, val: 20}}},
+			}
+
+			// Highs no one beats, so both lookbacks are checked.
+			qu := &recordingQuerier{points: []query.Point{{Time: day.AddDate(0, 0, -1), Value: 1000}}}
+			rc := counterbaseRecordser{qu: qu}
+			records, _, err := rc.records(context.Background(), day, cs, tt.width)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, q := range qu.queries {
+				if strings.Contains(q, "counter_id in ('a','b')") {
+					got = append(got, q)
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d sum queries, want %d: %q", len(got), len(tt.want), got)
+			}
+			for i, w := range tt.want {
+				if !strings.Contains(got[i], w) {
+					t.Errorf("sum query %d = %s, want %s", i, got[i], w)
+				}
+			}
+			if tt.want == nil {
+				if _, ok := records["sum"]; ok {
+					t.Errorf("records = %v, want no sum record without history", records)
+				}
+			}
+		})
+	}
+}
@@ -106,6 +106,46 @@ def validate_color_scale(scale):
         raise ValueError(f"unsupported color scale: {scale}")
 
 
+def prepare_weather_row(row, x_values):
+    data = pd.DataFrame(np.nan, index=[row.get("name", "Weather")], columns=x_values, dtype=float)
+    for value in row.get("values", []):
+        key = value.get("x")
+        if key in x_values:
+            data.iat[0, x_values.index(key)] = value.get("value")
+    return data
+
+
+def draw_weather_row(ax, row, data, font_size):
+    precision = row.get("precision", 0)
+    annotations = data.map(lambda v: f"{v:.{precision}f}" if pd.notna(v) and v != 0 else "")
+    sns.heatmap(
+        data,
+        cmap=row.get("color_map", "coolwarm"),
+        linewidths=0.2,
+        linecolor="gray",
+        annot=annotations,
+        fmt="",
+        square=False,
+        ax=ax,
+        mask=data.isna(),
+        cbar=False,
+        annot_kws={"fontsize": font_size, "style": "italic"},
+    )
+    # Set the weather row apart from the counter rows.
+    ax.set_facecolor("whitesmoke")
+    for spine in ax.spines.values():
+        spine.set_visible(True)
+        spine.set_linestyle("--")
+
+
+def shade_dark(ax, parsed, x_values):
+    for key in parsed.get("dark_x_values") or []:
+        if key not in x_values:
+            continue
+        pos = x_values.index(key)
+        ax.axvspan(pos, pos + 1, color="midnightblue", alpha=0.2, linewidth=0, zorder=3)
+
+
 def set_ticks(ax, parsed, x_values):
     font_size = parsed.get("x_tick_font", 12)
     rotation = parsed.get("x_tick_rotation", 0)
@@ -150,13 +190,24 @@ def main():
 
     cell_width = parsed.get("cell_width", 0.6)
     cell_height = parsed.get("cell_height", cell_width)
-    square = parsed.get("square", True)
+    # Square cells would not line up with the weather row.
+    square = parsed.get("square", True) and not parsed.get("weather_row")
 
     width = num_columns * cell_width
     height = num_counters * cell_height
 
-    fig = plt.figure(figsize=(width, height), dpi=300)
-    ax = fig.add_subplot(111)
+    weather_row = parsed.get("weather_row")
+    if weather_row:
+        fig, (ax, weather_ax) = plt.subplots(
+            2,
+            1,
+            figsize=(width, height + cell_height * 1.5),
+            dpi=300,
+            gridspec_kw={"height_ratios": [num_counters, 1], "hspace": 0.15},
+        )
+    else:
+        fig = plt.figure(figsize=(width, height), dpi=300)
+        ax = fig.add_subplot(111)
 
     sns.heatmap(
         color_data,
@@ -179,8 +230,24 @@ def main():
     ax.set_xlabel(parsed.get("x_label", ""), fontsize=axis_font, labelpad=5)
     ax.set_ylabel(parsed.get("y_label", ""), fontsize=axis_font, labelpad=5)
 
-    set_ticks(ax, parsed, x_values)
-    ax.set_yticklabels(ax.get_yticklabels(), rotation=0, fontsize=parsed.get("y_tick_font", 12))
+    shade_dark(ax, parsed, x_values)
+
+    y_tick_font = parsed.get("y_tick_font", 12)
+    ax.set_yticklabels(ax.get_yticklabels(), rotation=0, fontsize=y_tick_font)
+
+    if weather_row:
+        weather_data = prepare_weather_row(weather_row, x_values)
+        draw_weather_row(weather_ax, weather_row, weather_data, parsed.get("annotation_font", 10))
+        shade_dark(weather_ax, parsed, x_values)
+        # The x axis belongs under the weather row.
+        ax.set_xlabel("")
+        ax.set_xticks([])
+        weather_ax.set_xlabel(parsed.get("x_label", ""), fontsize=axis_font, labelpad=5)
+        weather_ax.set_ylabel("")
+        weather_ax.set_yticklabels(weather_ax.get_yticklabels(), rotation=0, fontsize=y_tick_font)
+        set_ticks(weather_ax, parsed, x_values)
+    else:
+        set_ticks(ax, parsed, x_values)
 
     plt.tight_layout()
 
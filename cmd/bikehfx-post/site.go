@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -48,7 +49,7 @@ func newSiteCmd(rootConfig *rootConfig) *ffcli.Command {
 				return errutil.With(err)
 			}
 
-			return generateSite(ctx, *outputDir, asOfDay, *topN, *writeSectionIndex, rootConfig.ccd, rootConfig.trq)
+			return generateSite(ctx, *outputDir, asOfDay, *topN, *writeSectionIndex, rootConfig.ccd, rootConfig.trq, rootConfig.rj, rootConfig.cm, rootConfig.wr, rootConfig.weather.coordinates())
 		},
 	}
 }
@@ -83,6 +84,18 @@ type sitePageFrontMatter struct {
 	YearHeatmaps    []siteYearHeatmapChart `json:"year_heatmaps,omitempty"`
 	Charts          map[string]string      `json:"charts,omitempty"`
 	StatusRows      []siteStatusRowFM      `json:"status_rows,omitempty"`
+	RecordRows      []siteRecordRowFM      `json:"record_rows,omitempty"`
+}
+
+type siteRecordRowFM struct {
+	Period     string `json:"period"`
+	Width      string `json:"width"`
+	Kind       string `json:"kind"`
+	Counter    string `json:"counter"`
+	CounterURL string `json:"counter_url,omitempty"`
+	Count      int    `json:"count"`
+	Previous   int    `json:"previous,omitempty"`
+	PreviousAt string `json:"previous_at,omitempty"`
 }
 
 type siteStatusRowFM struct {
@@ -104,7 +117,7 @@ type sitePeriodValue struct {
 	Count int    `json:"count"`
 }
 
-func generateSite(ctx context.Context, outputDir string, asOfDay time.Time, topN int, writeSectionIndex bool, ccd cyclingCounterDirectory, trq counterbaseTimeRangeQuerier) error {
+func generateSite(ctx context.Context, outputDir string, asOfDay time.Time, topN int, writeSectionIndex bool, ccd cyclingCounterDirectory, trq counterbaseTimeRangeQuerier, rj *recordJournal, cm *countModel, wr weatherer, coords coordinates) error {
 	asOfDay = time.Date(asOfDay.Year(), asOfDay.Month(), asOfDay.Day(), 0, 0, 0, 0, asOfDay.Location())
 	asOfEnd := asOfDay.AddDate(0, 0, 1)
 
@@ -153,6 +166,26 @@ func generateSite(ctx context.Context, outputDir string, asOfDay time.Time, topN
 		return errutil.With(err)
 	}
 
+	if rj != nil {
+		entries, err := rj.entries()
+		if err != nil {
+			return errutil.With(err)
+		}
+		if err := writeRecordsPage(outputDir, asOfDay, entries); err != nil {
+			return errutil.With(err)
+		}
+	}
+
+	if err := writeDaylightPage(ctx, outputDir, asOfDay, asOfEnd, counters, trq, coords); err != nil {
+		return errutil.With(err)
+	}
+
+	if cm != nil {
+		if err := writeModelPage(ctx, outputDir, asOfDay, asOfEnd, cm, trq, wr); err != nil {
+			return errutil.With(err)
+		}
+	}
+
 	return nil
 }
 
@@ -552,6 +585,168 @@ func writeCounterStatusPage(ctx context.Context, outputDir string, asOfDay, asOf
 	return writeMarkdownPage(filepath.Join(outputDir, "status", "_index.md"), fm, body.String())
 }
 
+// siteRecordRowsLimit caps how many recent record breaks the records page lists.
+const siteRecordRowsLimit = 50
+
+func writeRecordsPage(outputDir string, asOfDay time.Time, entries []recordJournalEntry) error {
+	asOf := asOfDay.Format("2006-01-02")
+	entries = slices.DeleteFunc(slices.Clone(entries), func(e recordJournalEntry) bool {
+		return e.Period > asOf
+	})
+	slices.SortStableFunc(entries, func(a, b recordJournalEntry) int {
+		if c := strings.Compare(b.Period, a.Period); c != 0 {
+			return c
+		}
+		return int(a.Width - b.Width)
+	})
+	if len(entries) > siteRecordRowsLimit {
+		entries = entries[:siteRecordRowsLimit]
+	}
+
+	rows := make([]siteRecordRowFM, 0, len(entries))
+	for _, e := range entries {
+		row := siteRecordRowFM{
+			Period:     e.Period,
+			Width:      recordWidthName(e.Width),
+			Kind:       recordKindName(e.Kind),
+			Counter:    e.CounterName,
+			Count:      e.Value,
+			Previous:   e.Previous,
+			PreviousAt: e.PreviousAt,
+		}
+		if e.CounterID != "sum" {
+			row.CounterURL = "../" + counterSlug(directory.Counter{ID: e.CounterID}) + "/"
+		}
+		rows = append(rows, row)
+	}
+
+	fm := sitePageFrontMatter{
+		Title:      "Records",
+		Type:       "bikehfxstats-records",
+		AsOf:       asOf,
+		RecordRows: rows,
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "Records broken in posts through %s.\n", asOf)
+	if len(rows) > 0 {
+		body.WriteString("\n| Period | Width | Kind | Counter | Count | Previous |\n")
+		body.WriteString("|---|---|---|---|---:|---:|\n")
+		for _, row := range rows {
+			fmt.Fprintf(&body, "| %s | %s | %s | %s | %d | %d |\n", row.Period, row.Width, row.Kind, row.Counter, row.Count, row.Previous)
+		}
+	}
+
+	return writeMarkdownPage(filepath.Join(outputDir, "records", "_index.md"), fm, body.String())
+}
+
+// siteDaylightDays is how many recent days the daylight chart covers.
+const siteDaylightDays = 365
+
+func writeDaylightPage(ctx context.Context, outputDir string, asOfDay, asOfEnd time.Time, counters []directory.Counter, trq counterbaseTimeRangeQuerier, coords coordinates) error {
+	tr := timeRange{begin: asOfEnd.AddDate(0, 0, -siteDaylightDays), end: asOfEnd}
+
+	totals := make(map[time.Time]int)
+	for _, counter := range counters {
+		counts, err := dayCountsForRange(ctx, trq, counter.ID, tr)
+		if err != nil {
+			return errutil.With(err)
+		}
+		for day, count := range counts {
+			totals[day] += count
+		}
+	}
+
+	var points []daylightCount
+	for _, d := range tr.splitDate(0, 0, 1) {
+		sun := coords.sun(d.begin)
+		if totals[d.begin] <= 0 || !sun.ok() {
+			continue
+		}
+		points = append(points, daylightCount{daylight: sun.daylight(), count: totals[d.begin]})
+	}
+
+	pageDir := filepath.Join(outputDir, "daylight")
+	if err := os.MkdirAll(pageDir, 0o755); err != nil {
+		return errutil.With(err)
+	}
+
+	fm := sitePageFrontMatter{
+		Title:  "Daylight",
+		Type:   "bikehfxstats-daylight",
+		AsOf:   asOfDay.Format("2006-01-02"),
+		Charts: make(map[string]string),
+	}
+
+	var body strings.Builder
+	today := coords.sun(asOfDay)
+	if t := today.text(); t != "" {
+		fmt.Fprintf(&body, "%s on %s.\n", t, asOfDay.Format("Jan 2"))
+	}
+
+	if len(points) > 0 {
+		img, err := daylightCountChart(points, fmt.Sprintf("Daily totals by hours of daylight, last %d days", siteDaylightDays))
+		if err != nil {
+			return errutil.With(err)
+		}
+		filename := "counts-vs-daylight.png"
+		if err := os.WriteFile(filepath.Join(pageDir, filename), img, 0o644); err != nil {
+			return errutil.With(err)
+		}
+		fm.Charts["counts_vs_daylight"] = filename
+		fmt.Fprintf(&body, "\n![Daily totals by hours of daylight](%s)\n", filename)
+	}
+
+	return writeMarkdownPage(filepath.Join(pageDir, "_index.md"), fm, body.String())
+}
+
+// siteModelDays is how many recent days the model residuals chart covers.
+const siteModelDays = 90
+
+func writeModelPage(ctx context.Context, outputDir string, asOfDay, asOfEnd time.Time, cm *countModel, trq counterbaseTimeRangeQuerier, wr weatherer) error {
+	tr := timeRange{begin: asOfEnd.AddDate(0, 0, -siteModelDays), end: asOfEnd}
+	obs, err := collectModelObservations(ctx, trq, wr, cm.Counters, tr)
+	if err != nil {
+		return errutil.With(err)
+	}
+
+	pageDir := filepath.Join(outputDir, "model")
+	if err := os.MkdirAll(pageDir, 0o755); err != nil {
+		return errutil.With(err)
+	}
+
+	fm := sitePageFrontMatter{
+		Title:  "Expected Counts",
+		Type:   "bikehfxstats-model",
+		AsOf:   asOfDay.Format("2006-01-02"),
+		Charts: make(map[string]string),
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "Daily totals for %d counters compared with the count expected for the weekday, season and weather, using a model fit on %s to %s.\n", len(cm.Counters), cm.Begin, cm.End)
+
+	residuals := modelResiduals(cm, obs)
+	if len(residuals) > 0 {
+		img, err := modelResidualChart(residuals, fmt.Sprintf("Daily totals vs expected, last %d days", siteModelDays))
+		if err != nil {
+			return errutil.With(err)
+		}
+		filename := "residuals.png"
+		if err := os.WriteFile(filepath.Join(pageDir, filename), img, 0o644); err != nil {
+			return errutil.With(err)
+		}
+		fm.Charts["residuals"] = filename
+
+		var absSum float64
+		for _, r := range residuals {
+			absSum += math.Abs(r.pct)
+		}
+		fmt.Fprintf(&body, "\nOver %d days with weather the model was off by %.0f%% on average.\n\n![Daily totals vs expected](%s)\n", len(residuals), absSum/float64(len(residuals)), filename)
+	}
+
+	return writeMarkdownPage(filepath.Join(pageDir, "_index.md"), fm, body.String())
+}
+
 func statusRowsFrontMatter(rows []siteCounterStatusRow) []siteStatusRowFM {
 	out := make([]siteStatusRowFM, 0, len(rows))
 	for _, row := range rows {
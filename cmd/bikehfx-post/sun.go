@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/graxinc/errutil"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+)
+
+// coordinates is where counting happens, for local sun calculations.
+type coordinates struct {
+	latitude, longitude float64
+}
+
+// sunDay is the sunrise and sunset for a day. Both are zero when the sun does
+// not rise or set that day.
+type sunDay struct {
+	sunrise, sunset time.Time
+}
+
+func (s sunDay) ok() bool {
+	return !s.sunrise.IsZero()
+}
+
+func (s sunDay) daylight() time.Duration {
+	return s.sunset.Sub(s.sunrise)
+}
+
+// dark reports whether most of the hour beginning at t is before sunrise or
+// after sunset.
+func (s sunDay) dark(t time.Time) bool {
+	mid := t.Add(30 * time.Minute)
+	return mid.Before(s.sunrise) || mid.After(s.sunset)
+}
+
+// text returns a line like "Daylight 5:30 AM–9:03 PM (15h33m)".
+func (s sunDay) text() string {
+	if !s.ok() {
+		return ""
+	}
+	d := s.daylight().Round(time.Minute)
+	return fmt.Sprintf("Daylight %s–%s (%dh%02dm)", s.sunrise.Format("3:04 PM"), s.sunset.Format("3:04 PM"), int(d.Hours()), int(d.Minutes())%60)
+}
+
+// sun returns sunrise and sunset on day's date, in day's location, using the
+// NOAA solar calculator approximations. They are good to about a minute at
+// mid latitudes.
+func (c coordinates) sun(day time.Time) sunDay {
+	midnight := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+
+	// Julian centuries since J2000.0, at noon UTC.
+	jd := float64(midnight.Unix())/86400 + 2440587.5 + 0.5
+	t := (jd - 2451545) / 36525
+
+	meanLong := math.Mod(280.46646+t*(36000.76983+t*0.0003032), 360)
+	meanAnom := 357.52911 + t*(35999.05029-0.0001537*t)
+	ecc := 0.016708634 - t*(0.000042037+0.0000001267*t)
+	center := sinDeg(meanAnom)*(1.914602-t*(0.004817+0.000014*t)) +
+		sinDeg(2*meanAnom)*(0.019993-0.000101*t) +
+		sinDeg(3*meanAnom)*0.000289
+	omega := 125.04 - 1934.136*t
+	appLong := meanLong + center - 0.00569 - 0.00478*sinDeg(omega)
+	meanObliq := 23 + (26+(21.448-t*(46.815+t*(0.00059-t*0.001813)))/60)/60
+	obliq := meanObliq + 0.00256*cosDeg(omega)
+	decl := degrees(math.Asin(sinDeg(obliq) * sinDeg(appLong)))
+
+	y := math.Pow(math.Tan(radians(obliq/2)), 2)
+	eqTime := 4 * degrees(y*sinDeg(2*meanLong)-
+		2*ecc*sinDeg(meanAnom)+
+		4*ecc*y*sinDeg(meanAnom)*cosDeg(2*meanLong)-
+		0.5*y*y*sinDeg(4*meanLong)-
+		1.25*ecc*ecc*sinDeg(2*meanAnom))
+
+	// 90.833 degrees allows for refraction and the size of the sun's disc.
+	cosHA := cosDeg(90.833)/(cosDeg(c.latitude)*cosDeg(decl)) - math.Tan(radians(c.latitude))*math.Tan(radians(decl))
+	if cosHA < -1 || cosHA > 1 {
+		return sunDay{}
+	}
+	ha := degrees(math.Acos(cosHA))
+
+	solarNoon := 720 - 4*c.longitude - eqTime // minutes after midnight UTC
+	minutes := func(m float64) time.Time {
+		return midnight.Add(time.Duration(m * float64(time.Minute))).In(day.Location())
+	}
+	return sunDay{sunrise: minutes(solarNoon - 4*ha), sunset: minutes(solarNoon + 4*ha)}
+}
+
+func radians(deg float64) float64 { return deg * math.Pi / 180 }
+func degrees(rad float64) float64 { return rad * 180 / math.Pi }
+func sinDeg(deg float64) float64  { return math.Sin(radians(deg)) }
+func cosDeg(deg float64) float64  { return math.Cos(radians(deg)) }
+
+type daylightCount struct {
+	daylight time.Duration
+	count    int
+}
+
+func daylightCountChart(points []daylightCount, title string) ([]byte, error) {
+	if err := initGraph(); err != nil {
+		return nil, errutil.With(err)
+	}
+
+	p := plot.New()
+
+	p.Title.Text = title
+	p.Title.Padding = vg.Length(5)
+
+	p.X.Label.Text = "Hours of daylight"
+	p.Y.Min = 0
+	p.Y.Label.Text = "Count"
+	p.Y.Label.Padding = vg.Length(5)
+	p.Y.Tick.Marker = plot.TickerFunc(thousandTicker(p.Y.Tick.Marker))
+
+	xys := make(plotter.XYs, 0, len(points))
+	for _, pt := range points {
+		xys = append(xys, plotter.XY{X: pt.daylight.Hours(), Y: float64(pt.count)})
+	}
+
+	scatter, err := plotter.NewScatter(xys)
+	if err != nil {
+		return nil, errutil.With(err)
+	}
+	scatter.Radius = vg.Points(2)
+	p.Add(scatter)
+
+	wt, err := p.WriterTo(20*vg.Centimeter, 10*vg.Centimeter, "png")
+	if err != nil {
+		return nil, errutil.With(err)
+	}
+
+	var b bytes.Buffer
+	if _, err := wt.WriteTo(&b); err != nil {
+		return nil, errutil.With(err)
+	}
+
+	if err := padImage(&b); err != nil {
+		return nil, errutil.With(err)
+	}
+
+	return b.Bytes(), nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCoordinatesSun(t *testing.T) {
+	t.Parallel()
+
+	loc, err := time.LoadLocation("America/Halifax")
+	if err != nil {
+		t.Fatal(err)
+	}
+	halifax := coordinates{latitude: 44.6488, longitude: -63.5752}
+
+	cases := []struct {
+		day             time.Time
+		sunrise, sunset string
+	}{
+		{time.Date(2023, 6, 21, 0, 0, 0, 0, loc), "5:30 AM", "9:03 PM"},
+		{time.Date(2023, 12, 21, 0, 0, 0, 0, loc), "7:47 AM", "4:35 PM"},
+	}
+	for _, c := range cases {
+		s := halifax.sun(c.day)
+		for _, got := range []struct {
+			name string
+			at   time.Time
+			want string
+		}{{"sunrise", s.sunrise, c.sunrise}, {"sunset", s.sunset, c.sunset}} {
+			want, err := time.ParseInLocation("2006-01-02 3:04 PM", c.day.Format("2006-01-02 ")+got.want, loc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d := got.at.Sub(want); d < -2*time.Minute || d > 2*time.Minute {
+				t.Errorf("%v %s = %v, want %v", c.day.Format("Jan 2"), got.name, got.at.Format("3:04:05 PM"), got.want)
+			}
+		}
+	}
+
+	if s := (coordinates{latitude: 78.2, longitude: 15.6}).sun(time.Date(2023, 6, 21, 0, 0, 0, 0, time.UTC)); s.ok() {
+		t.Errorf("got sunrise %v during polar day", s.sunrise)
+	}
+}
@@ -1,40 +1,242 @@
 package main
 
 import (
+	"cmp"
 	"context"
 	"encoding/csv"
+	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"io"
+	"log"
+	"math"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/dimchansky/utfbom"
 	"github.com/graxinc/errutil"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
 )
 
 type weather struct {
 	max, min   float64
 	rain, snow float64
+
+	gust    float64 // km/h
+	gustDir int     // degrees, 0 if unknown
+
+	// From hourly data, 0 if not available.
+	windChill float64 // lowest
+	humidex   float64 // highest
+
+	// provisional is set when the provider has not reported any
+	// precipitation for the day yet.
+	provisional bool
+}
+
+const (
+	weatherRaindrop  = "\U0001f4a7"
+	weatherSnowflake = "\u2744\ufe0f"
+	weatherWind      = "\U0001f4a8"
+	weatherCold      = "\U0001f976"
+	weatherHot       = "\U0001f975"
+)
+
+// withHourly fills in the lowest wind chill and highest humidex from hours.
+func (w weather) withHourly(hours []hourWeather) weather {
+	for _, h := range hours {
+		if h.windChill != 0 && (w.windChill == 0 || h.windChill < w.windChill) {
+			w.windChill = h.windChill
+		}
+		if h.humidex > w.humidex {
+			w.humidex = h.humidex
+		}
+	}
+	return w
+}
+
+// compassPoint returns the 8-point compass direction for deg.
+func compassPoint(deg int) string {
+	points := []string{"N", "NE", "E", "SE", "S", "SW", "W", "NW"}
+	return points[((deg*2+45)/90)%len(points)]
+}
+
+type weatherConfig struct {
+	providers commaSeparatedString
+
+	ecURL     string
+	ecStation string
+
+	openMeteoURL string
+
+	latitude, longitude float64
+
+	dir string
+}
+
+func (c *weatherConfig) registerFlags(fs *flag.FlagSet) {
+	c.providers = commaSeparatedString{vals: []string{"ec"}}
+	fs.Var(&c.providers, "weather-providers", "comma-separated weather providers to try in order (ec, open-meteo)")
+
+	fs.StringVar(&c.ecURL, "weather-ec-url", "https://climate.weather.gc.ca/climate_data/bulk_data_e.html", "Environment Canada bulk data URL")
+	fs.StringVar(&c.ecStation, "weather-ec-station", "50620", "Environment Canada climate station ID")
+
+	fs.StringVar(&c.openMeteoURL, "weather-open-meteo-url", "https://archive-api.open-meteo.com/v1/archive", "Open-Meteo compatible archive API URL")
+
+	fs.Float64Var(&c.latitude, "latitude", 44.6488, "latitude of the area being counted")
+	fs.Float64Var(&c.longitude, "longitude", -63.5752, "longitude of the area being counted")
+
+	fs.StringVar(&c.dir, "weather-dir", "", "if set, read weather only from EC bulk CSVs in this file or directory instead of the providers")
+}
+
+func (c weatherConfig) coordinates() coordinates {
+	return coordinates{latitude: c.latitude, longitude: c.longitude}
+}
+
+// newWeatherer reads weather from cfg.dir if it is set. Otherwise it builds
+// the configured providers, each caching fetched months under cacheDir if it
+// is set.
+func newWeatherer(cfg weatherConfig, cacheDir string) (weatherer, error) {
+	if cfg.dir != "" {
+		return newFileWeatherer(cfg.dir), nil
+	}
+
+	var out fallbackWeatherer
+	for _, p := range cfg.providers.vals {
+		var mw monthWeatherer
+		switch p {
+		case "ec":
+			mw = ecWeatherer{baseURL: cfg.ecURL, stationID: cfg.ecStation}
+		case "open-meteo":
+			mw = openMeteoWeatherer{baseURL: cfg.openMeteoURL, latitude: cfg.latitude, longitude: cfg.longitude}
+		default:
+			return nil, errutil.New(errutil.Tags{"msg": "unknown weather provider", "provider": p})
+		}
+		out = append(out, newCachingWeatherer(mw, cacheDir))
+	}
+	if len(out) == 0 {
+		return nil, errutil.New(errutil.Tags{"msg": "no weather providers"})
+	}
+	if len(out) == 1 {
+		return out[0], nil
+	}
+	return out, nil
+}
+
+// fallbackWeatherer returns weather from the first weatherer that has it.
+type fallbackWeatherer []weatherer
+
+func (f fallbackWeatherer) weather(ctx context.Context, day time.Time) (weather, error) {
+	var errs []error
+	for _, w := range f {
+		wt, err := w.weather(ctx, day)
+		if err == nil {
+			return wt, nil
+		}
+		errs = append(errs, errutil.With(err))
+	}
+	return weather{}, errors.Join(errs...)
+}
+
+func (f fallbackWeatherer) rangeWeather(ctx context.Context, tr timeRange) map[string]weather {
+	out := make(map[string]weather)
+	n := len(tr.splitDate(0, 0, 1))
+	for _, w := range f {
+		if len(out) == n {
+			break
+		}
+		for date, wt := range weatherForRange(ctx, w, tr) {
+			if _, ok := out[date]; !ok {
+				out[date] = wt
+			}
+		}
+	}
+	return out
+}
+
+// rangeWeatherer is implemented by weatherers that can look up all days of a
+// range together.
+type rangeWeatherer interface {
+	rangeWeather(ctx context.Context, tr timeRange) map[string]weather
+}
+
+// weatherForRange returns the weather for the days of tr that wr has, keyed
+// by YYYY-MM-DD date.
+func weatherForRange(ctx context.Context, wr weatherer, tr timeRange) map[string]weather {
+	if rw, ok := wr.(rangeWeatherer); ok {
+		return rw.rangeWeather(ctx, tr)
+	}
+	out := make(map[string]weather)
+	for _, d := range tr.splitDate(0, 0, 1) {
+		if w, err := wr.weather(ctx, d.begin); err == nil {
+			out[d.begin.Format("2006-01-02")] = w
+		}
+	}
+	return out
+}
+
+// splitDaysByMonth returns the start of each day in tr, grouped by month.
+func splitDaysByMonth(tr timeRange) [][]time.Time {
+	var out [][]time.Time
+	for _, d := range tr.splitDate(0, 0, 1) {
+		if i := len(out) - 1; i >= 0 && out[i][0].Month() == d.begin.Month() && out[i][0].Year() == d.begin.Year() {
+			out[i] = append(out[i], d.begin)
+			continue
+		}
+		out = append(out, []time.Time{d.begin})
+	}
+	return out
 }
 
 type ecWeatherer struct {
+	baseURL   string
+	stationID string
+}
+
+func (e ecWeatherer) cacheKey() string {
+	return "ec-" + e.stationID
 }
 
-func (ecWeatherer) weather(ctx context.Context, day time.Time) (weather, error) {
-	u, err := url.Parse("https://climate.weather.gc.ca/climate_data/bulk_data_e.html")
+func (e ecWeatherer) monthWeather(ctx context.Context, month time.Time) (map[string]weather, error) {
+	var days map[string]weather
+	err := e.fetch(ctx, month, "2", func(r io.Reader) error {
+		var err error
+		days, err = parseECDailyCSV(r)
+		return err
+	})
 	if err != nil {
-		return weather{}, errutil.With(err)
+		return nil, errutil.With(err)
+	}
+
+	// Only keep the requested month so cached months stay independent.
+	prefix := month.Format("2006-01-")
+	for date := range days {
+		if !strings.HasPrefix(date, prefix) {
+			delete(days, date)
+		}
+	}
+	return days, nil
+}
+
+// fetch downloads the bulk CSV for month at the given timeframe (1 for
+// hourly, 2 for daily) and passes it to parse.
+func (e ecWeatherer) fetch(ctx context.Context, month time.Time, timeframe string, parse func(io.Reader) error) error {
+	u, err := url.Parse(e.baseURL)
+	if err != nil {
+		return errutil.With(err)
 	}
 	q := u.Query()
 	q.Set("format", "csv")
-	q.Set("stationID", "50620")
-	q.Set("Year", fmt.Sprintf("%d", day.Year()))
-	q.Set("Month", fmt.Sprintf("%d", day.Month()))
-	q.Set("Day", fmt.Sprintf("%d", day.Day()))
-	q.Set("timeframe", "2")
+	q.Set("stationID", e.stationID)
+	q.Set("Year", fmt.Sprintf("%d", month.Year()))
+	q.Set("Month", fmt.Sprintf("%d", month.Month()))
+	q.Set("Day", "1")
+	q.Set("timeframe", timeframe)
 	q.Set("submit", "Download Data")
 	u.RawQuery = q.Encode()
 
@@ -43,24 +245,33 @@ func (ecWeatherer) weather(ctx context.Context, day time.Time) (weather, error)
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
 	if err != nil {
-		return weather{}, errutil.With(err)
+		return errutil.With(err)
 	}
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return weather{}, errutil.With(err)
+		return errutil.With(err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return weather{}, errutil.New(errutil.Tags{"code": resp.StatusCode})
+		return errutil.New(errutil.Tags{"code": resp.StatusCode})
 	}
 
-	cr := csv.NewReader(utfbom.SkipOnly(resp.Body))
+	if err := parse(resp.Body); err != nil {
+		return errutil.With(err)
+	}
+	return nil
+}
+
+// parseECDailyCSV parses an Environment Canada daily bulk CSV into weather
+// keyed by YYYY-MM-DD date. Days without min/max temperatures are skipped.
+func parseECDailyCSV(r io.Reader) (map[string]weather, error) {
+	cr := csv.NewReader(utfbom.SkipOnly(r))
 
 	header, err := cr.Read()
 	if err != nil {
-		return weather{}, errutil.With(err)
+		return nil, errutil.With(err)
 	}
 	headerIndexes := make(map[string]int)
 	for i, h := range header {
@@ -68,67 +279,287 @@ func (ecWeatherer) weather(ctx context.Context, day time.Time) (weather, error)
 	}
 	const dateHeader = "Date/Time"
 	if _, ok := headerIndexes[dateHeader]; !ok {
-		return weather{}, errutil.New(errutil.Tags{"msg": "could not find header " + dateHeader})
+		return nil, errutil.New(errutil.Tags{"msg": "could not find header " + dateHeader})
 	}
 
-	wantDate := day.Format("2006-01-02")
+	const (
+		maxTempHeader = "Max Temp (°C)"
+		minTempHeader = "Min Temp (°C)"
+		totalRain     = "Total Rain (mm)"
+		totalSnow     = "Total Snow (cm)"
+		totalPrecip   = "Total Precip (mm)"
+		gustSpeed     = "Spd of Max Gust (km/h)"
+		gustDir       = "Dir of Max Gust (10s deg)"
+	)
 
-	var dateRow []string
+	col := func(row []string, header string) string {
+		i, ok := headerIndexes[header]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return row[i]
+	}
+
+	out := make(map[string]weather)
 	for {
 		row, err := cr.Read()
 		if errors.Is(err, io.EOF) {
-			return weather{}, errutil.New(errutil.Tags{"msg": "could not find row for " + wantDate})
+			break
 		}
 		if err != nil {
-			return weather{}, errutil.With(err)
+			return nil, errutil.With(err)
 		}
 
-		if row[headerIndexes[dateHeader]] == wantDate {
-			dateRow = row
-			break
+		maxTempRaw := col(row, maxTempHeader)
+		minTempRaw := col(row, minTempHeader)
+		if maxTempRaw == "" || minTempRaw == "" {
+			continue
 		}
+		maxTemp, err := strconv.ParseFloat(maxTempRaw, 64)
+		if err != nil {
+			return nil, errutil.With(err)
+		}
+		minTemp, err := strconv.ParseFloat(minTempRaw, 64)
+		if err != nil {
+			return nil, errutil.With(err)
+		}
+
+		w := weather{
+			min: minTemp,
+			max: maxTemp,
+		}
+
+		rainRaw := col(row, totalRain)
+		snowRaw := col(row, totalSnow)
+		w.provisional = rainRaw == "" && snowRaw == "" && col(row, totalPrecip) == ""
+		if rainRaw != "" {
+			rain, err := strconv.ParseFloat(rainRaw, 64)
+			if err == nil && rain > 0 {
+				w.rain = rain
+			}
+		}
+		if snowRaw != "" {
+			snow, err := strconv.ParseFloat(snowRaw, 64)
+			if err == nil && snow > 0 {
+				w.snow = snow
+			}
+		}
+		// Gusts below the reporting threshold are given as "<31".
+		if gust, err := strconv.ParseFloat(col(row, gustSpeed), 64); err == nil && gust > 0 {
+			w.gust = gust
+			if dir, err := strconv.Atoi(col(row, gustDir)); err == nil && dir > 0 {
+				w.gustDir = dir * 10
+			}
+		}
+
+		out[col(row, dateHeader)] = w
 	}
 
-	const (
-		maxTempHeader = "Max Temp (°C)"
-		minTempHeader = "Min Temp (°C)"
-		totalRain     = "Total Rain (mm)"
-		totalSnow     = "Total Snow (cm)"
-	)
+	return out, nil
+}
+
+type openMeteoWeatherer struct {
+	baseURL             string
+	latitude, longitude float64
+}
 
-	maxTempRaw := dateRow[headerIndexes[maxTempHeader]]
-	minTempRaw := dateRow[headerIndexes[minTempHeader]]
-	if maxTempRaw == "" || minTempRaw == "" {
-		return weather{}, errutil.New(errutil.Tags{"msg": "could not find min/max temp for " + wantDate})
+type openMeteoDaily struct {
+	Time           []string   `json:"time"`
+	TemperatureMax []*float64 `json:"temperature_2m_max"`
+	TemperatureMin []*float64 `json:"temperature_2m_min"`
+	RainSum        []*float64 `json:"rain_sum"`
+	SnowfallSum    []*float64 `json:"snowfall_sum"`
+	GustMax        []*float64 `json:"wind_gusts_10m_max"`
+	WindDirection  []*float64 `json:"wind_direction_10m_dominant"`
+}
+
+func (o openMeteoWeatherer) cacheKey() string {
+	return "open-meteo-" + strconv.FormatFloat(o.latitude, 'f', -1, 64) + "," + strconv.FormatFloat(o.longitude, 'f', -1, 64)
+}
+
+func (o openMeteoWeatherer) monthWeather(ctx context.Context, month time.Time) (map[string]weather, error) {
+	u, err := url.Parse(o.baseURL)
+	if err != nil {
+		return nil, errutil.With(err)
 	}
-	maxTemp, err := strconv.ParseFloat(maxTempRaw, 64)
+
+	begin := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, month.Location())
+	end := begin.AddDate(0, 1, -1)
+	// The archive rejects end dates in the future.
+	if now := time.Now().In(month.Location()); end.After(now) {
+		end = now
+	}
+	if begin.After(end) {
+		return map[string]weather{}, nil
+	}
+
+	q := u.Query()
+	q.Set("latitude", strconv.FormatFloat(o.latitude, 'f', -1, 64))
+	q.Set("longitude", strconv.FormatFloat(o.longitude, 'f', -1, 64))
+	q.Set("start_date", begin.Format("2006-01-02"))
+	q.Set("end_date", end.Format("2006-01-02"))
+	q.Set("daily", "temperature_2m_max,temperature_2m_min,rain_sum,snowfall_sum,wind_gusts_10m_max,wind_direction_10m_dominant")
+	q.Set("timezone", month.Location().String())
+	u.RawQuery = q.Encode()
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
 	if err != nil {
-		return weather{}, errutil.With(err)
+		return nil, errutil.With(err)
 	}
-	minTemp, err := strconv.ParseFloat(minTempRaw, 64)
+
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return weather{}, errutil.With(err)
+		return nil, errutil.With(err)
 	}
+	defer resp.Body.Close()
 
-	w := weather{
-		min: minTemp,
-		max: maxTemp,
+	if resp.StatusCode != http.StatusOK {
+		return nil, errutil.New(errutil.Tags{"code": resp.StatusCode})
 	}
 
-	rainRaw := dateRow[headerIndexes[totalRain]]
-	if rainRaw != "" {
-		rain, err := strconv.ParseFloat(rainRaw, 64)
-		if err == nil && rain > 0 {
-			w.rain = rain
+	var body struct {
+		Daily openMeteoDaily `json:"daily"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, errutil.With(err)
+	}
+	d := body.Daily
+
+	out := make(map[string]weather)
+	for i, date := range d.Time {
+		maxTemp, minTemp := openMeteoValue(d.TemperatureMax, i), openMeteoValue(d.TemperatureMin, i)
+		if maxTemp == nil || minTemp == nil {
+			continue
+		}
+
+		w := weather{max: *maxTemp, min: *minTemp}
+		rain, snow := openMeteoValue(d.RainSum, i), openMeteoValue(d.SnowfallSum, i)
+		w.provisional = rain == nil && snow == nil
+		if rain != nil && *rain > 0 {
+			w.rain = *rain
 		}
+		if snow != nil && *snow > 0 {
+			w.snow = *snow
+		}
+		// Open-Meteo has no direction of the strongest gust, so use the
+		// dominant wind direction.
+		if gust := openMeteoValue(d.GustMax, i); gust != nil && *gust > 0 {
+			w.gust = *gust
+			if dir := openMeteoValue(d.WindDirection, i); dir != nil {
+				w.gustDir = cmp.Or(int(math.Round(*dir))%360, 360)
+			}
+		}
+		out[date] = w
+	}
+
+	return out, nil
+}
+
+func openMeteoValue(vals []*float64, i int) *float64 {
+	if i >= len(vals) {
+		return nil
 	}
-	snowRaw := dateRow[headerIndexes[totalSnow]]
-	if snowRaw != "" {
-		snow, err := strconv.ParseFloat(snowRaw, 64)
-		if err == nil && snow > 0 {
-			w.snow = snow
+	return vals[i]
+}
+
+// weatherSummary aggregates daily weather over a period.
+type weatherSummary struct {
+	days             int
+	meanMax, meanMin float64
+	rain, snow       float64
+	wetDays          int
+	freezingDays     int
+}
+
+// wetDayPrecip is the rain in mm or snow in cm at which a day counts as wet.
+const wetDayPrecip = 1.0
+
+func summarizeWeather(ctx context.Context, wr weatherer, tr timeRange) weatherSummary {
+	byDate := weatherForRange(ctx, wr, tr)
+
+	var ws weatherSummary
+	var missing int
+	for _, d := range tr.splitDate(0, 0, 1) {
+		w, ok := byDate[d.begin.Format("2006-01-02")]
+		if !ok {
+			missing++
+			continue
+		}
+		ws.days++
+		ws.meanMax += w.max
+		ws.meanMin += w.min
+		ws.rain += w.rain
+		ws.snow += w.snow
+		if w.rain >= wetDayPrecip || w.snow >= wetDayPrecip {
+			ws.wetDays++
 		}
+		if w.min < 0 {
+			ws.freezingDays++
+		}
+	}
+	if missing > 0 {
+		log.Printf("weather summary for %v: missing %d days", tr, missing)
+	}
+	if ws.days > 0 {
+		ws.meanMax /= float64(ws.days)
+		ws.meanMin /= float64(ws.days)
+	}
+	return ws
+}
+
+// text returns a temperature and precipitation line plus, if any, a line
+// counting wet and below-freezing days.
+func (ws weatherSummary) text() string {
+	if ws.days == 0 {
+		return ""
+	}
+	p := message.NewPrinter(language.English)
+
+	var out strings.Builder
+	p.Fprintf(&out, "Avg %v/%v C", int(math.Round(ws.meanMax)), int(math.Round(ws.meanMin)))
+	if ws.rain > 0 {
+		p.Fprintf(&out, " %v %.1fmm", weatherRaindrop, ws.rain)
+	}
+	if ws.snow > 0 {
+		p.Fprintf(&out, " %v %.1fcm", weatherSnowflake, ws.snow)
 	}
 
-	return w, nil
+	var counts []string
+	if ws.wetDays > 0 {
+		counts = append(counts, p.Sprintf("%d wet %v", ws.wetDays, plural(ws.wetDays, "day", "days")))
+	}
+	if ws.freezingDays > 0 {
+		counts = append(counts, p.Sprintf("%d %v below freezing", ws.freezingDays, plural(ws.freezingDays, "day", "days")))
+	}
+	if len(counts) > 0 {
+		p.Fprintf(&out, "\n%v", strings.Join(counts, ", "))
+	}
+
+	return out.String()
+}
+
+// shortText is a compact form for comparison lines.
+func (ws weatherSummary) shortText() string {
+	if ws.days == 0 {
+		return ""
+	}
+	p := message.NewPrinter(language.English)
+	out := p.Sprintf("%v/%v C", int(math.Round(ws.meanMax)), int(math.Round(ws.meanMin)))
+	if ws.rain > 0 {
+		out += p.Sprintf(" %v %.0fmm", weatherRaindrop, ws.rain)
+	}
+	if ws.snow > 0 {
+		out += p.Sprintf(" %v %.0fcm", weatherSnowflake, ws.snow)
+	}
+	return out
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
 }
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/graxinc/errutil"
+)
+
+// monthWeatherer fetches weather for all available days of a month at once.
+type monthWeatherer interface {
+	monthWeather(ctx context.Context, month time.Time) (map[string]weather, error)
+	cacheKey() string
+}
+
+// weatherFinalizeAfter is how long after a month ends its data is treated as
+// final. Provider data often lags by several days.
+const weatherFinalizeAfter = 10 * 24 * time.Hour
+
+// monthCache keeps whole months of per-day values, keyed by YYYY-MM-DD date,
+// in memory and, if dir is set, on disk. Months that were not yet final when
+// fetched are refetched when stale, at most once per run. A failed fetch is
+// remembered for the rest of the run.
+type monthCache[T any] struct {
+	dir string
+	now func() time.Time
+
+	months map[string]*cachedMonth[T]
+	failed map[string]error
+}
+
+type cachedMonth[T any] struct {
+	FetchedAt time.Time    `json:"fetched_at"`
+	Days      map[string]T `json:"days"`
+
+	fetchedThisRun bool
+}
+
+func newMonthCache[T any](dir string) *monthCache[T] {
+	return &monthCache[T]{
+		dir:    dir,
+		now:    time.Now,
+		months: make(map[string]*cachedMonth[T]),
+		failed: make(map[string]error),
+	}
+}
+
+// month returns the cached month beginning at month, first fetching it if it
+// is not yet final and stale reports it needs refreshing. If the fetch fails,
+// the month cached before, if any, is returned with the error.
+func (c *monthCache[T]) month(ctx context.Context, month time.Time, stale func(*cachedMonth[T]) bool, fetch func(context.Context, time.Time) (map[string]T, error)) (*cachedMonth[T], error) {
+	monthKey := month.Format("2006-01")
+
+	m := c.months[monthKey]
+	if m == nil {
+		loaded, err := c.load(monthKey)
+		if err != nil {
+			return nil, errutil.With(err)
+		}
+		m = loaded
+	}
+	if m != nil {
+		c.months[monthKey] = m
+
+		final := m.FetchedAt.After(month.AddDate(0, 1, 0).Add(weatherFinalizeAfter))
+		if final || m.fetchedThisRun || !stale(m) {
+			return m, nil
+		}
+	}
+
+	if err := c.failed[monthKey]; err != nil {
+		return m, err
+	}
+	days, err := fetch(ctx, month)
+	if err != nil {
+		c.failed[monthKey] = err
+		return m, errutil.With(err)
+	}
+
+	m = &cachedMonth[T]{FetchedAt: c.now(), Days: days, fetchedThisRun: true}
+	if err := c.save(monthKey, m); err != nil {
+		return nil, errutil.With(err)
+	}
+	c.months[monthKey] = m
+	return m, nil
+}
+
+func (c *monthCache[T]) path(monthKey string) string {
+	return filepath.Join(c.dir, monthKey+".json")
+}
+
+func (c *monthCache[T]) load(monthKey string) (*cachedMonth[T], error) {
+	if c.dir == "" {
+		return nil, nil
+	}
+
+	b, err := os.ReadFile(c.path(monthKey))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errutil.With(err)
+	}
+
+	var m cachedMonth[T]
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, errutil.With(err)
+	}
+	return &m, nil
+}
+
+func (c *monthCache[T]) save(monthKey string, m *cachedMonth[T]) error {
+	if c.dir == "" {
+		return nil
+	}
+
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return errutil.With(err)
+	}
+	return writeFileAtomic(c.path(monthKey), b)
+}
+
+// cacheSubdir returns the directory under cacheDir for a provider's cached
+// months, or "" if cacheDir is not set.
+func cacheSubdir(cacheDir string, elem ...string) string {
+	if cacheDir == "" {
+		return ""
+	}
+	return filepath.Join(append([]string{cacheDir}, elem...)...)
+}
+
+// cachingWeatherer serves daily weather from whole-month fetches through a
+// monthCache. Months that were not yet final are refetched when a requested
+// day is missing or provisional. Days cached before a failed fetch are served
+// in its place.
+type cachingWeatherer struct {
+	src monthWeatherer
+
+	mu     sync.Mutex
+	months *monthCache[cachedWeather]
+}
+
+// cachedWeather is the on-disk form of weather.
+type cachedWeather struct {
+	Max  float64 `json:"max"`
+	Min  float64 `json:"min"`
+	Rain float64 `json:"rain,omitempty"`
+	Snow float64 `json:"snow,omitempty"`
+
+	Gust    float64 `json:"gust,omitempty"`
+	GustDir int     `json:"gust_dir,omitempty"`
+
+	Provisional bool `json:"provisional,omitempty"`
+}
+
+func newCachedWeather(w weather) cachedWeather {
+	return cachedWeather{Max: w.max, Min: w.min, Rain: w.rain, Snow: w.snow, Gust: w.gust, GustDir: w.gustDir, Provisional: w.provisional}
+}
+
+func (c cachedWeather) weather() weather {
+	return weather{max: c.Max, min: c.Min, rain: c.Rain, snow: c.Snow, gust: c.Gust, gustDir: c.GustDir, provisional: c.Provisional}
+}
+
+func newCachingWeatherer(src monthWeatherer, dir string) *cachingWeatherer {
+	return &cachingWeatherer{
+		src:    src,
+		months: newMonthCache[cachedWeather](cacheSubdir(dir, src.cacheKey())),
+	}
+}
+
+func (c *cachingWeatherer) weather(ctx context.Context, day time.Time) (weather, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	wantDate := day.Format("2006-01-02")
+	m, err := c.month(ctx, day, []string{wantDate})
+	if m != nil {
+		if cw, ok := m.Days[wantDate]; ok {
+			return cw.weather(), nil
+		}
+	}
+	if err != nil {
+		return weather{}, errutil.With(err)
+	}
+	return weather{}, errutil.New(errutil.Tags{"msg": "could not find weather for " + wantDate})
+}
+
+// rangeWeather returns the weather for the days of tr that are available,
+// looking up each month once.
+func (c *cachingWeatherer) rangeWeather(ctx context.Context, tr timeRange) map[string]weather {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	out := make(map[string]weather)
+	for _, days := range splitDaysByMonth(tr) {
+		dates := make([]string, len(days))
+		for i, d := range days {
+			dates[i] = d.Format("2006-01-02")
+		}
+		m, _ := c.month(ctx, days[0], dates)
+		if m == nil {
+			continue
+		}
+		for _, date := range dates {
+			if cw, ok := m.Days[date]; ok {
+				out[date] = cw.weather()
+			}
+		}
+	}
+	return out
+}
+
+// month returns the cached month containing day, refetching it if any of
+// dates is missing or provisional. c.mu must be held.
+func (c *cachingWeatherer) month(ctx context.Context, day time.Time, dates []string) (*cachedMonth[cachedWeather], error) {
+	month := time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, day.Location())
+	stale := func(m *cachedMonth[cachedWeather]) bool {
+		return slices.ContainsFunc(dates, func(date string) bool {
+			cw, ok := m.Days[date]
+			return !ok || cw.Provisional
+		})
+	}
+	return c.months.month(ctx, month, stale, func(ctx context.Context, month time.Time) (map[string]cachedWeather, error) {
+		days, err := c.src.monthWeather(ctx, month)
+		if err != nil {
+			return nil, errutil.With(err)
+		}
+		out := make(map[string]cachedWeather, len(days))
+		for date, w := range days {
+			out[date] = newCachedWeather(w)
+		}
+		return out, nil
+	})
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/dimchansky/utfbom"
+	"github.com/graxinc/errutil"
+)
+
+// fileWeatherer serves weather from EC bulk CSVs on disk, either a single file
+// or a directory of them. Daily and hourly files are told apart by their
+// headers and may be mixed.
+type fileWeatherer struct {
+	path string
+
+	once   sync.Once
+	err    error
+	days   map[string]weather
+	hourly [][]byte
+
+	hoursOnce sync.Once
+	hoursErr  error
+	hours     []hourWeather
+}
+
+func newFileWeatherer(path string) *fileWeatherer {
+	return &fileWeatherer{path: path}
+}
+
+func (f *fileWeatherer) weather(ctx context.Context, day time.Time) (weather, error) {
+	if err := f.load(); err != nil {
+		return weather{}, errutil.With(err)
+	}
+
+	date := day.Format("2006-01-02")
+	w, ok := f.days[date]
+	if !ok {
+		return weather{}, errutil.New(errutil.Tags{"msg": "could not find weather for " + date, "path": f.path})
+	}
+	return w, nil
+}
+
+func (f *fileWeatherer) hourlyWeather(ctx context.Context, day time.Time) ([]hourWeather, error) {
+	if err := f.load(); err != nil {
+		return nil, errutil.With(err)
+	}
+
+	// Hourly times need a location to be read in, so parse them on first use.
+	f.hoursOnce.Do(func() {
+		lst := ecStandardTime(day.Location())
+		for _, b := range f.hourly {
+			hours, err := parseECHourlyCSV(bytes.NewReader(b), lst)
+			if err != nil {
+				f.hoursErr = errutil.With(err)
+				return
+			}
+			f.hours = append(f.hours, hours...)
+		}
+	})
+	if f.hoursErr != nil {
+		return nil, f.hoursErr
+	}
+
+	begin := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	end := begin.AddDate(0, 0, 1)
+
+	var out []hourWeather
+	for _, h := range f.hours {
+		if h.at.Before(begin) || !h.at.Before(end) {
+			continue
+		}
+		h.at = h.at.In(day.Location())
+		out = append(out, h)
+	}
+	return out, nil
+}
+
+func (f *fileWeatherer) load() error {
+	f.once.Do(func() {
+		f.err = f.loadFiles()
+	})
+	return f.err
+}
+
+func (f *fileWeatherer) loadFiles() error {
+	paths := []string{f.path}
+	if fi, err := os.Stat(f.path); err != nil {
+		return errutil.With(err)
+	} else if fi.IsDir() {
+		paths, err = filepath.Glob(filepath.Join(f.path, "*.csv"))
+		if err != nil {
+			return errutil.With(err)
+		}
+		// Later files win for days present in more than one.
+		slices.Sort(paths)
+	}
+
+	f.days = make(map[string]weather)
+	for _, p := range paths {
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return errutil.With(err)
+		}
+
+		header, err := csv.NewReader(utfbom.SkipOnly(bytes.NewReader(b))).Read()
+		if err != nil {
+			return errutil.Witht(err, errutil.Tags{"path": p})
+		}
+		if slices.Contains(header, "Date/Time (LST)") {
+			f.hourly = append(f.hourly, b)
+			continue
+		}
+
+		days, err := parseECDailyCSV(bytes.NewReader(b))
+		if err != nil {
+			return errutil.Witht(err, errutil.Tags{"path": p})
+		}
+		for date, w := range days {
+			f.days[date] = w
+		}
+	}
+	return nil
+}
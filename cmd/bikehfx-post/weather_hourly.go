@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dimchansky/utfbom"
+	"github.com/graxinc/errutil"
+)
+
+// hourWeather is the weather observed during the hour beginning at.
+type hourWeather struct {
+	at      time.Time
+	temp    float64
+	hasTemp bool
+	precip  float64
+	desc    string
+
+	windChill float64 // 0 if not reported
+	humidex   float64 // 0 if not reported
+}
+
+type hourlyWeatherer interface {
+	hourlyWeather(ctx context.Context, day time.Time) ([]hourWeather, error)
+}
+
+// newHourlyWeatherer returns the hourly weather source for cfg, or nil if no
+// configured provider has hourly data. If wr, built by newWeatherer, has
+// hourly data itself, as when reading from cfg.dir, it is shared. Fetched
+// months are cached under cacheDir if it is set.
+func newHourlyWeatherer(cfg weatherConfig, wr weatherer, cacheDir string) hourlyWeatherer {
+	if hw, ok := wr.(hourlyWeatherer); ok {
+		return hw
+	}
+	if slices.Contains(cfg.providers.vals, "ec") {
+		return newCachingHourlyWeatherer(ecWeatherer{baseURL: cfg.ecURL, stationID: cfg.ecStation}, cacheDir)
+	}
+	return nil
+}
+
+// monthHourlyWeatherer fetches hourly weather for a whole month at once.
+type monthHourlyWeatherer interface {
+	monthHourlyWeather(ctx context.Context, month time.Time) ([]hourWeather, error)
+	cacheKey() string
+}
+
+func (e ecWeatherer) monthHourlyWeather(ctx context.Context, month time.Time) ([]hourWeather, error) {
+	var hours []hourWeather
+	err := e.fetch(ctx, month, "1", func(r io.Reader) error {
+		var err error
+		hours, err = parseECHourlyCSV(r, month.Location())
+		return err
+	})
+	if err != nil {
+		return nil, errutil.With(err)
+	}
+	return hours, nil
+}
+
+// cachingHourlyWeatherer serves hourly weather from whole-month fetches
+// through a monthCache, kept apart from daily months by timeframe. Months
+// that were not yet final are refetched when a requested day has no hours.
+type cachingHourlyWeatherer struct {
+	src monthHourlyWeatherer
+
+	mu     sync.Mutex
+	months *monthCache[[]cachedHour]
+}
+
+// cachedHour is the on-disk form of hourWeather.
+type cachedHour struct {
+	At      time.Time `json:"at"`
+	Temp    float64   `json:"temp,omitempty"`
+	HasTemp bool      `json:"has_temp,omitempty"`
+	Precip  float64   `json:"precip,omitempty"`
+	Desc    string    `json:"desc,omitempty"`
+
+	WindChill float64 `json:"wind_chill,omitempty"`
+	Humidex   float64 `json:"humidex,omitempty"`
+}
+
+func newCachingHourlyWeatherer(src monthHourlyWeatherer, dir string) *cachingHourlyWeatherer {
+	return &cachingHourlyWeatherer{
+		src:    src,
+		months: newMonthCache[[]cachedHour](cacheSubdir(dir, src.cacheKey(), "hourly")),
+	}
+}
+
+func (c *cachingHourlyWeatherer) hourlyWeather(ctx context.Context, day time.Time) ([]hourWeather, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	begin := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	end := begin.AddDate(0, 0, 1)
+
+	// Hourly data is in standard time, so the local day can start in the
+	// previous month's file.
+	lst := ecStandardTime(day.Location())
+	var dates []string
+	for _, t := range []time.Time{begin.In(lst), end.Add(-time.Hour).In(lst)} {
+		if d := t.Format("2006-01-02"); !slices.Contains(dates, d) {
+			dates = append(dates, d)
+		}
+	}
+
+	var out []hourWeather
+	for _, date := range dates {
+		t, err := time.ParseInLocation("2006-01-02", date, lst)
+		if err != nil {
+			return nil, errutil.With(err)
+		}
+		month := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, lst)
+		stale := func(m *cachedMonth[[]cachedHour]) bool {
+			return len(m.Days[date]) == 0
+		}
+		m, err := c.months.month(ctx, month, stale, c.fetch)
+		if m == nil {
+			return nil, errutil.With(err)
+		}
+		for _, ch := range m.Days[date] {
+			if ch.At.Before(begin) || !ch.At.Before(end) {
+				continue
+			}
+			out = append(out, hourWeather{
+				at:        ch.At.In(day.Location()),
+				temp:      ch.Temp,
+				hasTemp:   ch.HasTemp,
+				precip:    ch.Precip,
+				desc:      ch.Desc,
+				windChill: ch.WindChill,
+				humidex:   ch.Humidex,
+			})
+		}
+	}
+	return out, nil
+}
+
+// fetch returns month's hours grouped by standard time date.
+func (c *cachingHourlyWeatherer) fetch(ctx context.Context, month time.Time) (map[string][]cachedHour, error) {
+	hours, err := c.src.monthHourlyWeather(ctx, month)
+	if err != nil {
+		return nil, errutil.With(err)
+	}
+	out := make(map[string][]cachedHour)
+	for _, h := range hours {
+		date := h.at.In(month.Location()).Format("2006-01-02")
+		out[date] = append(out[date], cachedHour{
+			At:        h.at,
+			Temp:      h.temp,
+			HasTemp:   h.hasTemp,
+			Precip:    h.precip,
+			Desc:      h.desc,
+			WindChill: h.windChill,
+			Humidex:   h.humidex,
+		})
+	}
+	return out, nil
+}
+
+// ecStandardTime returns the zone EC hourly data uses, local standard time
+// all year. It assumes loc is in the northern hemisphere.
+func ecStandardTime(loc *time.Location) *time.Location {
+	name, offset := time.Date(time.Now().Year(), time.January, 1, 0, 0, 0, 0, loc).Zone()
+	return time.FixedZone(name, offset)
+}
+
+// parseECHourlyCSV parses an Environment Canada hourly bulk CSV with times in
+// lst. Hours without any observations are skipped.
+func parseECHourlyCSV(r io.Reader, lst *time.Location) ([]hourWeather, error) {
+	cr := csv.NewReader(utfbom.SkipOnly(r))
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, errutil.With(err)
+	}
+	headerIndexes := make(map[string]int)
+	for i, h := range header {
+		headerIndexes[h] = i
+	}
+	const dateHeader = "Date/Time (LST)"
+	if _, ok := headerIndexes[dateHeader]; !ok {
+		return nil, errutil.New(errutil.Tags{"msg": "could not find header " + dateHeader})
+	}
+
+	const (
+		tempHeader    = "Temp (°C)"
+		precipHeader  = "Precip. Amount (mm)"
+		weatherHeader = "Weather"
+		humidexHeader = "Hmdx"
+		chillHeader   = "Wind Chill"
+	)
+
+	col := func(row []string, header string) string {
+		i, ok := headerIndexes[header]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return row[i]
+	}
+
+	var out []hourWeather
+	for {
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, errutil.With(err)
+		}
+
+		at, err := time.ParseInLocation("2006-01-02 15:04", col(row, dateHeader), lst)
+		if err != nil {
+			return nil, errutil.With(err)
+		}
+		h := hourWeather{at: at}
+
+		if raw := col(row, tempHeader); raw != "" {
+			temp, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return nil, errutil.With(err)
+			}
+			h.temp, h.hasTemp = temp, true
+		}
+		if raw := col(row, precipHeader); raw != "" {
+			precip, err := strconv.ParseFloat(raw, 64)
+			if err == nil && precip > 0 {
+				h.precip = precip
+			}
+		}
+		if desc := col(row, weatherHeader); desc != "NA" {
+			h.desc = desc
+		}
+		if v, err := strconv.ParseFloat(col(row, chillHeader), 64); err == nil {
+			h.windChill = v
+		}
+		if v, err := strconv.ParseFloat(col(row, humidexHeader), 64); err == nil {
+			h.humidex = v
+		}
+
+		if !h.hasTemp && h.precip == 0 && h.desc == "" {
+			continue
+		}
+		out = append(out, h)
+	}
+
+	return out, nil
+}
+
+// rainy reports whether it rained during the hour, by amount or, for stations
+// that do not report hourly amounts, by description.
+func (h hourWeather) rainy() bool {
+	if h.precip >= 0.2 {
+		return true
+	}
+	desc := strings.ToLower(h.desc)
+	return strings.Contains(desc, "rain") || strings.Contains(desc, "drizzle")
+}
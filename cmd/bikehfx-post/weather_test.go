@@ -0,0 +1,397 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestOpenMeteoWeatherer(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("start_date") != "2023-07-01" || q.Get("end_date") != "2023-07-31" || q.Get("timezone") != "America/Halifax" || q.Get("latitude") != "44.6488" {
+			http.Error(w, "unexpected query "+r.URL.RawQuery, http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"daily":{"time":["2023-07-21"],"temperature_2m_max":[24.6],"temperature_2m_min":[15.1],"rain_sum":[3.2],"snowfall_sum":[null]}}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	loc, err := time.LoadLocation("America/Halifax")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wr := openMeteoWeatherer{baseURL: srv.URL, latitude: 44.6488, longitude: -63.5752}
+	got, err := wr.monthWeather(context.Background(), time.Date(2023, 7, 1, 0, 0, 0, 0, loc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := cmp.Diff(map[string]weather{"2023-07-21": {max: 24.6, min: 15.1, rain: 3.2}}, got, cmp.AllowUnexported(weather{})); d != "" {
+		t.Error(d)
+	}
+
+	// Months that have not begun are not requested.
+	next := time.Now().In(loc).AddDate(0, 1, 0)
+	got, err = wr.monthWeather(context.Background(), time.Date(next.Year(), next.Month(), 1, 0, 0, 0, 0, loc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("got %v for a future month, want none", got)
+	}
+}
+
+func TestFallbackWeatherer(t *testing.T) {
+	t.Parallel()
+
+	ec := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// EC publishes rows for days it has no data for yet.
+		w.Write([]byte("\"Date/Time\",\"Max Temp (°C)\",\"Min Temp (°C)\",\"Total Rain (mm)\",\"Total Snow (cm)\"\n\"2023-07-21\",\"\",\"\",\"\",\"\"\n"))
+	}))
+	t.Cleanup(ec.Close)
+
+	om := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"daily":{"time":["2023-07-21"],"temperature_2m_max":[24.6],"temperature_2m_min":[15.1],"rain_sum":[0],"snowfall_sum":[0]}}`))
+	}))
+	t.Cleanup(om.Close)
+
+	cfg := weatherConfig{
+		providers:    commaSeparatedString{vals: []string{"ec", "open-meteo"}},
+		ecURL:        ec.URL,
+		ecStation:    "50620",
+		openMeteoURL: om.URL,
+	}
+	wr, err := newWeatherer(cfg, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := wr.weather(context.Background(), time.Date(2023, 7, 21, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := cmp.Diff(weather{max: 24.6, min: 15.1}, got, cmp.AllowUnexported(weather{})); d != "" {
+		t.Error(d)
+	}
+}
+
+type countingMonthWeatherer struct {
+	calls int
+	days  map[string]weather
+	err   error
+}
+
+func (c *countingMonthWeatherer) cacheKey() string { return "counting" }
+
+func (c *countingMonthWeatherer) monthWeather(context.Context, time.Time) (map[string]weather, error) {
+	c.calls++
+	if c.err != nil {
+		return nil, c.err
+	}
+	return maps.Clone(c.days), nil
+}
+
+func TestCachingWeatherer(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	src := &countingMonthWeatherer{days: map[string]weather{
+		"2023-07-20": {max: 20, min: 10},
+		"2023-07-21": {max: 21, min: 11, rain: 1},
+	}}
+
+	// Fetched before the month was final.
+	cw := newCachingWeatherer(src, dir)
+	cw.months.now = func() time.Time { return time.Date(2023, 7, 22, 0, 0, 0, 0, time.UTC) }
+
+	for _, d := range []int{20, 21, 20} {
+		if _, err := cw.weather(ctx, time.Date(2023, 7, d, 0, 0, 0, 0, time.UTC)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if src.calls != 1 {
+		t.Fatalf("calls = %d, want 1 fetch for the month", src.calls)
+	}
+
+	// A missing day is only refetched once per run.
+	for range 2 {
+		if _, err := cw.weather(ctx, time.Date(2023, 7, 22, 0, 0, 0, 0, time.UTC)); err == nil {
+			t.Fatal("expected error for missing day")
+		}
+	}
+	if src.calls != 1 {
+		t.Fatalf("calls = %d, want no refetch in the same run", src.calls)
+	}
+
+	// A later run refetches the unfinalized month for a missing day.
+	src.days["2023-07-22"] = weather{max: 22, min: 12}
+	cw = newCachingWeatherer(src, dir)
+	cw.months.now = func() time.Time { return time.Date(2023, 9, 1, 0, 0, 0, 0, time.UTC) }
+	got, err := cw.weather(ctx, time.Date(2023, 7, 22, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.max != 22 || src.calls != 2 {
+		t.Fatalf("got %+v after %d calls, want refetched day", got, src.calls)
+	}
+
+	// Once final, the month is served from disk even for missing days.
+	cw = newCachingWeatherer(src, dir)
+	if _, err := cw.weather(ctx, time.Date(2023, 7, 23, 0, 0, 0, 0, time.UTC)); err == nil {
+		t.Fatal("expected error for missing day")
+	}
+	if src.calls != 2 {
+		t.Fatalf("calls = %d, want finalized month served from disk", src.calls)
+	}
+}
+
+func TestCachingWeathererProvisional(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	day := time.Date(2023, 7, 21, 0, 0, 0, 0, time.UTC)
+	src := &countingMonthWeatherer{days: map[string]weather{
+		"2023-07-21": {max: 21, min: 11, provisional: true},
+	}}
+
+	cw := newCachingWeatherer(src, dir)
+	cw.months.now = func() time.Time { return time.Date(2023, 7, 22, 0, 0, 0, 0, time.UTC) }
+	if _, err := cw.weather(ctx, day); err != nil {
+		t.Fatal(err)
+	}
+
+	// A later run refetches the provisional day, which now has rain.
+	src.days["2023-07-21"] = weather{max: 21, min: 11, rain: 4}
+	cw = newCachingWeatherer(src, dir)
+	cw.months.now = func() time.Time { return time.Date(2023, 7, 25, 0, 0, 0, 0, time.UTC) }
+	got, err := cw.weather(ctx, day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.rain != 4 || src.calls != 2 {
+		t.Fatalf("got %+v after %d calls, want refetched rain", got, src.calls)
+	}
+
+	// A failing provider is tried once per run, serving what was cached.
+	src.days["2023-07-22"] = weather{max: 22, min: 12, provisional: true}
+	cw = newCachingWeatherer(src, dir)
+	cw.months.now = func() time.Time { return time.Date(2023, 7, 26, 0, 0, 0, 0, time.UTC) }
+	if _, err := cw.weather(ctx, day.AddDate(0, 0, 1)); err != nil {
+		t.Fatal(err)
+	}
+	src.err = errors.New("unavailable")
+	cw = newCachingWeatherer(src, dir)
+	for range 3 {
+		if _, err := cw.weather(ctx, day.AddDate(0, 0, 1)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := cw.weather(ctx, day.AddDate(0, 1, 0)); err == nil {
+			t.Fatal("expected error for a month never fetched")
+		}
+	}
+	if src.calls != 5 {
+		t.Fatalf("calls = %d, want one failed fetch per month", src.calls)
+	}
+}
+
+type mapWeatherer map[string]weather
+
+func (m mapWeatherer) weather(_ context.Context, day time.Time) (weather, error) {
+	w, ok := m[day.Format("2006-01-02")]
+	if !ok {
+		return weather{}, errors.New("no weather")
+	}
+	return w, nil
+}
+
+func TestSummarizeWeather(t *testing.T) {
+	t.Parallel()
+
+	wr := mapWeatherer{
+		"2023-01-01": {max: 2, min: -4, snow: 5.5},
+		"2023-01-02": {max: 4, min: 1, rain: 0.4},
+		"2023-01-03": {max: 6, min: -1, rain: 12},
+	}
+	tr := newTimeRangeDate(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), 0, 0, 4)
+
+	got := summarizeWeather(context.Background(), wr, tr)
+	want := weatherSummary{days: 3, meanMax: 4, meanMin: -4.0 / 3, rain: 12.4, snow: 5.5, wetDays: 2, freezingDays: 2}
+	if d := cmp.Diff(want, got, cmp.AllowUnexported(weatherSummary{})); d != "" {
+		t.Error(d)
+	}
+
+	if got, want := got.text(), "Avg 4/-1 C 💧 12.4mm ❄️ 5.5cm\n2 wet days, 2 days below freezing"; got != want {
+		t.Errorf("text() = %q, want %q", got, want)
+	}
+	if got, want := got.shortText(), "4/-1 C 💧 12mm ❄️ 6cm"; got != want {
+		t.Errorf("shortText() = %q, want %q", got, want)
+	}
+}
+
+func TestSummarizeWeatherByMonth(t *testing.T) {
+	t.Parallel()
+
+	days := make(map[string]weather)
+	for d := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC); d.Year() == 2023; d = d.AddDate(0, 0, 1) {
+		days[d.Format("2006-01-02")] = weather{max: 10, min: 2}
+	}
+	primary := &countingMonthWeatherer{days: days}
+	secondary := &countingMonthWeatherer{}
+	wr := fallbackWeatherer{newCachingWeatherer(primary, ""), newCachingWeatherer(secondary, "")}
+
+	got := summarizeWeather(context.Background(), wr, newTimeRangeDate(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), 1, 0, 0))
+	if got.days != 365 {
+		t.Errorf("got %d days, want 365", got.days)
+	}
+	if primary.calls != 12 || secondary.calls != 0 {
+		t.Errorf("got %d primary and %d secondary fetches, want 12 and 0", primary.calls, secondary.calls)
+	}
+}
+
+func TestECHourlyWeather(t *testing.T) {
+	t.Parallel()
+
+	var fetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		q := r.URL.Query()
+		if q.Get("timeframe") != "1" {
+			http.Error(w, "unexpected query "+r.URL.RawQuery, http.StatusBadRequest)
+			return
+		}
+		switch q.Get("Month") {
+		case "6":
+			w.Write([]byte("\"Date/Time (LST)\",\"Temp (°C)\",\"Precip. Amount (mm)\",\"Weather\"\n\"2023-06-30 23:00\",\"14.0\",\"\",\"NA\"\n"))
+		case "7":
+			w.Write([]byte("\"Date/Time (LST)\",\"Temp (°C)\",\"Precip. Amount (mm)\",\"Weather\"\n\"2023-07-01 00:00\",\"13.5\",\"0.0\",\"NA\"\n\"2023-07-01 15:00\",\"18.1\",\"2.4\",\"Rain\"\n\"2023-07-01 23:00\",\"\",\"\",\"\"\n"))
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	loc, err := time.LoadLocation("America/Halifax")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Hourly times are in standard time, an hour behind local daylight time.
+	wr := newCachingHourlyWeatherer(ecWeatherer{baseURL: srv.URL, stationID: "50620"}, t.TempDir())
+	wr.months.now = func() time.Time { return time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC) }
+	got, err := wr.hourlyWeather(context.Background(), time.Date(2023, 7, 1, 0, 0, 0, 0, loc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []hourWeather{
+		{at: time.Date(2023, 7, 1, 0, 0, 0, 0, loc), temp: 14, hasTemp: true},
+		{at: time.Date(2023, 7, 1, 1, 0, 0, 0, loc), temp: 13.5, hasTemp: true},
+		{at: time.Date(2023, 7, 1, 16, 0, 0, 0, loc), temp: 18.1, hasTemp: true, precip: 2.4, desc: "Rain"},
+	}
+	if d := cmp.Diff(want, got, cmp.AllowUnexported(hourWeather{})); d != "" {
+		t.Error(d)
+	}
+
+	// Other days of the month are served from the cached months.
+	for _, d := range []int{1, 2} {
+		if _, err := wr.hourlyWeather(context.Background(), time.Date(2023, 7, d, 0, 0, 0, 0, loc)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("got %d fetches, want one each for June and July", n)
+	}
+}
+
+func TestParseECDailyCSVGusts(t *testing.T) {
+	t.Parallel()
+
+	in := "\"Date/Time\",\"Max Temp (°C)\",\"Min Temp (°C)\",\"Total Rain (mm)\",\"Total Snow (cm)\",\"Dir of Max Gust (10s deg)\",\"Spd of Max Gust (km/h)\"\n" +
+		"\"2023-01-20\",\"-2.0\",\"-9.5\",\"\",\"3.0\",\"32\",\"61\"\n" +
+		"\"2023-01-21\",\"1.0\",\"-4.0\",\"\",\"\",\"\",\"<31\"\n"
+
+	got, err := parseECDailyCSV(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]weather{
+		"2023-01-20": {max: -2, min: -9.5, snow: 3, gust: 61, gustDir: 320},
+		"2023-01-21": {max: 1, min: -4, provisional: true},
+	}
+	if d := cmp.Diff(want, got, cmp.AllowUnexported(weather{})); d != "" {
+		t.Error(d)
+	}
+	if got := compassPoint(got["2023-01-20"].gustDir); got != "NW" {
+		t.Errorf("compassPoint = %q, want NW", got)
+	}
+}
+
+func TestWeatherWithHourly(t *testing.T) {
+	t.Parallel()
+
+	hours := []hourWeather{{windChill: -14}, {windChill: -19}, {}, {humidex: 26}}
+	got := weather{max: -2, min: -9}.withHourly(hours)
+	if got.windChill != -19 || got.humidex != 26 {
+		t.Errorf("withHourly = %+v, want wind chill -19 and humidex 26", got)
+	}
+}
+
+func TestFileWeatherer(t *testing.T) {
+	t.Parallel()
+
+	loc, err := time.LoadLocation("America/Halifax")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	fw := newFileWeatherer(filepath.Join("testdata", "weather", "en_climate_daily_NS_8202251_2023_P1D.csv"))
+	got, err := fw.weather(ctx, time.Date(2023, 7, 20, 0, 0, 0, 0, loc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := cmp.Diff(weather{max: 25.1, min: 14.2}, got, cmp.AllowUnexported(weather{})); d != "" {
+		t.Error(d)
+	}
+	// The file has a row for the day but no observations.
+	if _, err := fw.weather(ctx, time.Date(2023, 7, 22, 0, 0, 0, 0, loc)); err == nil {
+		t.Error("expected error for day without data")
+	}
+
+	// Daily and hourly weather share one reader of the directory.
+	cfg := weatherConfig{dir: filepath.Join("testdata", "weather")}
+	wr, err := newWeatherer(cfg, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw = wr.(*fileWeatherer)
+	if hw := newHourlyWeatherer(cfg, wr, ""); hw != hourlyWeatherer(fw) {
+		t.Fatalf("hourly weatherer %p is not the daily one %p", hw, fw)
+	}
+	hours, err := fw.hourlyWeather(ctx, time.Date(2023, 7, 21, 0, 0, 0, 0, loc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Local hours are an hour ahead of the file's standard time, so the
+	// file's 23:00 row is the next local day and local midnight is missing.
+	if len(hours) != 23 {
+		t.Fatalf("got %d hours, want 23", len(hours))
+	}
+	if h := hours[0]; h.at.Hour() != 1 {
+		t.Errorf("hours[0] = %+v, want the 1 AM local hour", h)
+	}
+	if h := hours[14]; h.at.Hour() != 15 || h.precip != 0.8 || h.desc != "Rain" {
+		t.Errorf("hours[14] = %+v, want rain in the 3 PM local hour", h)
+	}
+}
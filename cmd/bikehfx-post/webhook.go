@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/graxinc/errutil"
+)
+
+// webhookPoster sends each post to a chat webhook. Discord gets a multipart
+// payload with the post's images attached. Slack incoming webhooks only take
+// JSON, so they get the text with each image's alt text in place of the
+// image. Neither can reply to earlier messages, so threads are flattened into
+// consecutive messages.
+type webhookPoster struct {
+	client *http.Client
+	url    string
+	format string // discord or slack
+
+	mu sync.Mutex
+	n  int
+}
+
+func newWebhookPoster(url, format string) (*webhookPoster, error) {
+	switch format {
+	case "discord", "slack":
+	default:
+		return nil, errutil.New(errutil.Tags{"msg": "unknown webhook format", "format": format})
+	}
+	return &webhookPoster{client: &http.Client{Timeout: time.Minute}, url: url, format: format}, nil
+}
+
+func (w *webhookPoster) limits() postLimits {
+	if w.format == "discord" {
+		return postLimits{maxLength: 2000}
+	}
+	// Slack truncates longer text.
+	return postLimits{maxLength: 4000}
+}
+
+// mediaLimits returns Discord's upload limit for webhooks.
+func (w *webhookPoster) mediaLimits() mediaLimits {
+	return mediaLimits{maxBytes: 10 << 20}
+}
+
+func (w *webhookPoster) post(ctx context.Context, p post) (string, error) {
+	if w.format == "slack" {
+		return w.postSlack(ctx, p)
+	}
+
+	media := make([]adaptedMedia, len(p.media))
+	filenames := make([]string, len(p.media))
+	for i, m := range p.media {
+		am, err := adaptMedia(m.b, w.mediaLimits())
+		if err != nil {
+			return "", errutil.With(err)
+		}
+		media[i] = am
+		filenames[i] = fmt.Sprintf("image-%d.%s", i, strings.TrimPrefix(am.mimeType, "image/"))
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+
+	type attachment struct {
+		ID          int    `json:"id"`
+		Filename    string `json:"filename"`
+		Description string `json:"description,omitempty"`
+	}
+	payload := struct {
+		Content     string       `json:"content"`
+		Attachments []attachment `json:"attachments,omitempty"`
+	}{Content: p.text}
+	for i, m := range p.media {
+		payload.Attachments = append(payload.Attachments, attachment{ID: i, Filename: filenames[i], Description: m.altText})
+	}
+
+	pb, err := json.Marshal(payload)
+	if err != nil {
+		return "", errutil.With(err)
+	}
+	if err := mw.WriteField("payload_json", string(pb)); err != nil {
+		return "", errutil.With(err)
+	}
+
+	for i, am := range media {
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="files[%d]"; filename=%q`, i, filenames[i]))
+		h.Set("Content-Type", am.mimeType)
+		part, err := mw.CreatePart(h)
+		if err != nil {
+			return "", errutil.With(err)
+		}
+		if _, err := part.Write(am.b); err != nil {
+			return "", errutil.With(err)
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return "", errutil.With(err)
+	}
+
+	// Wait for the message so its ID comes back.
+	u := w.url
+	sep := "?"
+	if strings.Contains(u, "?") {
+		sep = "&"
+	}
+	u += sep + "wait=true"
+
+	rb, err := w.send(ctx, u, mw.FormDataContentType(), &body)
+	if err != nil {
+		return "", errutil.With(err)
+	}
+
+	var msg struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(rb, &msg); err != nil {
+		return "", errutil.With(err)
+	}
+	return msg.ID, nil
+}
+
+func (w *webhookPoster) postSlack(ctx context.Context, p post) (string, error) {
+	text := p.text
+	for _, m := range p.media {
+		if m.altText != "" {
+			text += "\n\n[Image: " + m.altText + "]"
+		}
+	}
+	pb, err := json.Marshal(struct {
+		Text string `json:"text"`
+	}{Text: text})
+	if err != nil {
+		return "", errutil.With(err)
+	}
+
+	if _, err := w.send(ctx, w.url, "application/json", bytes.NewReader(pb)); err != nil {
+		return "", errutil.With(err)
+	}
+
+	// Slack webhooks only say ok, so make up an ID for the post journal.
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.n++
+	return fmt.Sprintf("slack-%d-%d", time.Now().Unix(), w.n), nil
+}
+
+// send posts body to u, returning the response body.
+func (w *webhookPoster) send(ctx context.Context, u, contentType string, body io.Reader) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, body)
+	if err != nil {
+		return nil, errutil.With(err)
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, errutil.With(err)
+	}
+	defer resp.Body.Close()
+
+	rb, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, errutil.With(err)
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, errutil.New(errutil.Tags{"msg": "webhook request failed", "status": resp.StatusCode, "body": string(rb)})
+	}
+	return rb, nil
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type webhookRequest struct {
+	query   string
+	json    string // body of an application/json request
+	fields  map[string]string
+	files   map[string]string // form name to filename and content type
+	fileLen int
+}
+
+func newWebhookServer(t *testing.T, reply string) (*httptest.Server, func() []webhookRequest) {
+	var (
+		mu   sync.Mutex
+		reqs []webhookRequest
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		wr := webhookRequest{query: r.URL.RawQuery, fields: make(map[string]string), files: make(map[string]string)}
+		if r.Header.Get("Content-Type") == "application/json" {
+			b, _ := io.ReadAll(r.Body)
+			wr.json = string(b)
+			mu.Lock()
+			reqs = append(reqs, wr)
+			n := len(reqs)
+			mu.Unlock()
+			fmt.Fprintf(w, reply, n)
+			return
+		}
+
+		mr, err := r.MultipartReader()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			b, _ := io.ReadAll(part)
+			if part.FileName() == "" {
+				wr.fields[part.FormName()] = string(b)
+				continue
+			}
+			wr.files[part.FormName()] = part.FileName() + " " + part.Header.Get("Content-Type")
+			wr.fileLen += len(b)
+		}
+
+		mu.Lock()
+		reqs = append(reqs, wr)
+		n := len(reqs)
+		mu.Unlock()
+
+		fmt.Fprintf(w, reply, n)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, func() []webhookRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return reqs
+	}
+}
+
+func TestWebhookPosterDiscord(t *testing.T) {
+	t.Parallel()
+
+	srv, reqs := newWebhookServer(t, `{"id":"%d00"}`)
+	wp, err := newWebhookPoster(srv.URL+"/api/webhooks/1/abc", "discord")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pt := posterThreader{p: wp, name: "webhook"}
+	ids, err := pt.postThreadIDs(context.Background(), threadKey{kind: "daily", period: "20230721"}, []post{
+		{text: "579 #BikeHfx bikes counted", media: []postMedia{{b: testPNG(t, 40, 20, false), altText: "chart"}}},
+		{text: "second"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(ids, ","); got != "100,200" {
+		t.Errorf("ids = %s, want 100,200", got)
+	}
+
+	got := reqs()
+	if len(got) != 2 {
+		t.Fatalf("got %d requests, want 2", len(got))
+	}
+	if got[0].query != "wait=true" {
+		t.Errorf("query = %q, want wait=true", got[0].query)
+	}
+
+	var payload struct {
+		Content     string `json:"content"`
+		Attachments []struct {
+			ID          int    `json:"id"`
+			Filename    string `json:"filename"`
+			Description string `json:"description"`
+		} `json:"attachments"`
+	}
+	if err := json.Unmarshal([]byte(got[0].fields["payload_json"]), &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Content != "579 #BikeHfx bikes counted" || len(payload.Attachments) != 1 || payload.Attachments[0].Filename != "image-0.png" || payload.Attachments[0].Description != "chart" {
+		t.Errorf("payload = %+v", payload)
+	}
+	if f := got[0].files["files[0]"]; f != "image-0.png image/png" || got[0].fileLen == 0 {
+		t.Errorf("file = %q with %d bytes, want image-0.png image/png", f, got[0].fileLen)
+	}
+
+	// The thread is flattened, so the reply is just the next message.
+	if p := got[1].fields["payload_json"]; p != `{"content":"second"}` || len(got[1].files) != 0 {
+		t.Errorf("second payload = %s with %d files", p, len(got[1].files))
+	}
+}
+
+func TestWebhookPosterSlack(t *testing.T) {
+	t.Parallel()
+
+	srv, reqs := newWebhookServer(t, "ok")
+	wp, err := newWebhookPoster(srv.URL, "slack")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := wp.post(context.Background(), post{text: "579 bikes", media: []postMedia{{b: testPNG(t, 40, 20, false), altText: "chart"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(id, "slack-") {
+		t.Errorf("id = %q, want a slack- ID", id)
+	}
+
+	// Slack incoming webhooks only take JSON, so images are described.
+	got := reqs()[0]
+	if got.query != "" {
+		t.Errorf("query = %q, want none", got.query)
+	}
+	if got.json != `{"text":"579 bikes\n\n[Image: chart]"}` || len(got.fields) != 0 || len(got.files) != 0 {
+		t.Errorf("got JSON %s with fields %v and files %v, want only JSON text", got.json, got.fields, got.files)
+	}
+
+	if _, err := newWebhookPoster(srv.URL, "teams"); err == nil {
+		t.Error("got no error for an unknown format")
+	}
+}
@@ -18,10 +18,10 @@ import (
 func newWeeklyCmd(rootConfig *rootConfig) *ffcli.Command {
 	var (
 		fs    = flag.NewFlagSet("bikehfx-post weekly", flag.ExitOnError)
-		week  = fs.String("week", time.Now().AddDate(0, 0, -7).Format("20060102"), "week to post for, in YYYYMMDD form")
+		week  = fs.String("week", time.Now().AddDate(0, 0, -7).Format("20060102"), "any day of the week to post for, in YYYYMMDD form")
 		weeks commaSeparatedString
 	)
-	fs.Var(&weeks, "weeks", "comma-separated weeks to post, in YYYYMMDD form, preferred over week")
+	fs.Var(&weeks, "weeks", "comma-separated weeks to post, each as its own thread, in YYYYMMDD form, preferred over week")
 
 	return &ffcli.Command{
 		Name:       "weekly",
@@ -34,42 +34,48 @@ func newWeeklyCmd(rootConfig *rootConfig) *ffcli.Command {
 				weeks = []string{*week}
 			}
 
-			return weeklyExec(ctx, weeks, rootConfig.trq, rootConfig.rc, rootConfig.tp)
+			return weeklyExec(ctx, weeks, rootConfig.trq, rootConfig.wr, rootConfig.rc, rootConfig.tp, rootConfig.postedRecords())
 		},
 	}
 }
 
-func weeklyExec(ctx context.Context, weeks []string, trq counterbaseTimeRangeQuerier, rc recordser, tp threadPoster) error {
+func weeklyExec(ctx context.Context, weeks []string, trq counterbaseTimeRangeQuerier, wr weatherer, rc recordser, tp threadPoster, rj *recordJournal) error {
 	loc, err := time.LoadLocation("America/Halifax")
 	if err != nil {
 		return errutil.With(err)
 	}
 
-	var posts []post
+	var threads []periodThread
 	for _, week := range weeks {
 		weekt, err := time.ParseInLocation("20060102", week, loc)
 		if err != nil {
 			return errutil.With(err)
 		}
 
-		ps, err := weekPost(ctx, weekt, trq, rc)
+		key := threadKey{kind: "weekly", period: weekStart(weekt).Format("20060102")}
+		threads, err = addPeriodThread(threads, key, func() ([]post, []recordJournalEntry, error) {
+			return weekPost(ctx, weekt, trq, wr, rc)
+		})
 		if err != nil {
 			return errutil.With(err)
 		}
-
-		posts = append(posts, ps...)
 	}
 
-	if _, err := tp.postThread(ctx, posts); err != nil {
+	if err := postPeriodThreads(ctx, tp, rj, threads); err != nil {
 		return errutil.With(err)
 	}
 	return nil
 }
 
-func weekPost(ctx context.Context, weekt time.Time, trq counterbaseTimeRangeQuerier, rc recordser) ([]post, error) {
+// weekStart returns the start of the Sunday to Saturday week containing t.
+func weekStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day()-int(t.Weekday()), 0, 0, 0, 0, t.Location())
+}
+
+func weekPost(ctx context.Context, weekt time.Time, trq counterbaseTimeRangeQuerier, weatherer weatherer, rc recordser) ([]post, []recordJournalEntry, error) {
 	var posts []post
 
-	weekRange := newTimeRangeDate(time.Date(weekt.Year(), weekt.Month(), weekt.Day()-int(weekt.Weekday()), 0, 0, 0, 0, weekt.Location()), 0, 0, 7)
+	weekRange := newTimeRangeDate(weekStart(weekt), 0, 0, 7)
 
 	weekRanges := []timeRange{weekRange}
 	weekRangeYear, weekRangeNum := weekRange.begin.ISOWeek()
@@ -97,7 +103,7 @@ func weekPost(ctx context.Context, weekt time.Time, trq counterbaseTimeRangeQuer
 	for _, wr := range weekRanges {
 		weekSeries, err := trq.query(ctx, wr)
 		if err != nil {
-			return nil, errutil.With(err)
+			return nil, nil, errutil.With(err)
 		}
 
 		weeksSeries = append(weeksSeries, weekSeries)
@@ -113,12 +119,17 @@ func weekPost(ctx context.Context, weekt time.Time, trq counterbaseTimeRangeQuer
 			series:  c.series,
 		})
 	}
-	records, err := rc.records(ctx, weekRange.begin, cs, recordWidthWeek)
+	records, broken, err := rc.records(ctx, weekRange.begin, cs, recordWidthWeek)
 	if err != nil {
-		return nil, errutil.With(err)
+		return nil, nil, errutil.With(err)
 	}
 
-	weekPostText := weekPostText(weekRange, weeksSeries[0], records)
+	rangesWeather := make([]weatherSummary, len(weekRanges))
+	for i, r := range weekRanges {
+		rangesWeather[i] = summarizeWeather(ctx, weatherer, r)
+	}
+
+	weekPostText := weekPostText(weekRange, rangesWeather[0], weeksSeries[0], records)
 
 	graphBegin := weekRange.begin.AddDate(0, 0, -7*7)
 	graphRange := newTimeRangeDate(graphBegin, 0, 0, 8*7)
@@ -126,7 +137,7 @@ func weekPost(ctx context.Context, weekt time.Time, trq counterbaseTimeRangeQuer
 
 	graphCountSeries, err := trq.query(ctx, graphWeeks...)
 	if err != nil {
-		return nil, errutil.With(err)
+		return nil, nil, errutil.With(err)
 	}
 
 	weekCounts := make(map[time.Time]int)
@@ -148,7 +159,7 @@ func weekPost(ctx context.Context, weekt time.Time, trq counterbaseTimeRangeQuer
 
 		weekDaySeries, err := trq.query(ctx, weekDays...)
 		if err != nil {
-			return nil, errutil.With(err)
+			return nil, nil, errutil.With(err)
 		}
 
 		var xValues []string
@@ -187,7 +198,7 @@ func weekPost(ctx context.Context, weekt time.Time, trq counterbaseTimeRangeQuer
 
 		imgBytes, err := runUVScript(ctx, "heatmap.py", input)
 		if err != nil {
-			return nil, errutil.With(err)
+			return nil, nil, errutil.With(err)
 		}
 
 		hhs := []counterSeries{{series: []timeRangeValue{{}}}}
@@ -243,7 +254,7 @@ func weekPost(ctx context.Context, weekt time.Time, trq counterbaseTimeRangeQuer
 
 	gr, err := timeRangeBarGraph(graphTRVs, "Total count by week ending", func(tr timeRange) string { return tr.end.AddDate(0, 0, -1).Format("Jan 2") })
 	if err != nil {
-		return nil, errutil.With(err)
+		return nil, nil, errutil.With(err)
 	}
 
 	atg := altTextGenerator{
@@ -268,7 +279,7 @@ func weekPost(ctx context.Context, weekt time.Time, trq counterbaseTimeRangeQuer
 
 	altText, err := atg.text(graphTRVs)
 	if err != nil {
-		return nil, errutil.With(err)
+		return nil, nil, errutil.With(err)
 	}
 
 	p1.media = append(p1.media, postMedia{b: gr, altText: altText})
@@ -292,14 +303,18 @@ func weekPost(ctx context.Context, weekt time.Time, trq counterbaseTimeRangeQuer
 
 	prevWeeksPostPrinter := message.NewPrinter(language.English)
 	prevWeeksPostText := prevWeeksPostPrinter.Sprintf("Previous year counts for week %d:\n\n", weekRangeNum)
-	for _, trv := range graph2TRVs {
-		prevWeeksPostText += prevWeeksPostPrinter.Sprintf("%v: %v\n", trv.tr.end.Format("2006"), trv.val)
+	for i, trv := range graph2TRVs {
+		prevWeeksPostText += prevWeeksPostPrinter.Sprintf("%v: %v", trv.tr.end.Format("2006"), trv.val)
+		if wt := rangesWeather[i].shortText(); wt != "" {
+			prevWeeksPostText += " · " + wt
+		}
+		prevWeeksPostText += "\n"
 	}
 
 	slices.Reverse(graph2TRVs)
 	gr2, err := timeRangeBarGraph(graph2TRVs, prevWeeksPostPrinter.Sprintf("Total count for week %d by year", weekRangeNum), func(tr timeRange) string { return tr.end.Format("2006") })
 	if err != nil {
-		return nil, errutil.With(err)
+		return nil, nil, errutil.With(err)
 	}
 
 	atg2 := altTextGenerator{
@@ -324,7 +339,7 @@ func weekPost(ctx context.Context, weekt time.Time, trq counterbaseTimeRangeQuer
 
 	altText2, err := atg2.text(graph2TRVs)
 	if err != nil {
-		return nil, errutil.With(err)
+		return nil, nil, errutil.With(err)
 	}
 
 	const weeksPerYear = 52
@@ -341,7 +356,7 @@ func weekPost(ctx context.Context, weekt time.Time, trq counterbaseTimeRangeQuer
 	pastThreeYearsWeeks := pastThreeYears.splitDate(0, 0, 7)
 	pastThreeYearsWeeksSeries, err := trq.query(ctx, pastThreeYearsWeeks...)
 	if err != nil {
-		return nil, errutil.With(err)
+		return nil, nil, errutil.With(err)
 	}
 
 	var pastThreeYearsWeekCountsByYear = make(map[int]map[int]timeRangeValue)
@@ -363,7 +378,7 @@ func weekPost(ctx context.Context, weekt time.Time, trq counterbaseTimeRangeQuer
 
 	gr3, err := yearWeekChart(pastThreeYearsWeekCountsByYear, "Total count by week for recent years")
 	if err != nil {
-		return nil, errutil.With(err)
+		return nil, nil, errutil.With(err)
 	}
 
 	posts = append(posts, post{
@@ -374,10 +389,10 @@ func weekPost(ctx context.Context, weekt time.Time, trq counterbaseTimeRangeQuer
 		},
 	})
 
-	return posts, nil
+	return posts, broken, nil
 }
 
-func weekPostText(weekRange timeRange, cs []counterSeries, records map[string]recordKind) string {
+func weekPostText(weekRange timeRange, ws weatherSummary, cs []counterSeries, records map[string]recordKind) string {
 	var out strings.Builder
 
 	p := message.NewPrinter(language.English)
@@ -397,6 +412,9 @@ func weekPostText(weekRange timeRange, cs []counterSeries, records map[string]re
 	}
 
 	p.Fprintf(&out, "Week review:\n\n%v%v #BikeHfx bikes counted week ending %v\n\n", sum, recordSymbol(records["sum"]), weekRange.end.AddDate(0, 0, -1).Format("Mon Jan 2"))
+	if t := ws.text(); t != "" {
+		p.Fprintf(&out, "%v\n\n", t)
+	}
 
 	slices.SortFunc(presentIndices, func(i, j int) int {
 		return cmp.Compare(counterName(cs[i].counter), counterName(cs[j].counter))
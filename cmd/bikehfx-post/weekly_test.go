@@ -52,7 +52,7 @@ func TestWeeklyPostText(t *testing.T) {
 			"b":   recordKindYTD,
 		}
 
-		got := weekPostText(weekRange, cs, records)
+		got := weekPostText(weekRange, weatherSummary{days: 7, meanMax: 23.4, meanMin: 14.6, rain: 12.3, wetDays: 2}, cs, records)
 		expect(t, "text.txt", got)
 	})
 
@@ -60,7 +60,7 @@ func TestWeeklyPostText(t *testing.T) {
 		cs := []counterSeries{
 			makeSeries("a", "Apple", 123),
 		}
-		got := weekPostText(weekRange, cs, nil)
+		got := weekPostText(weekRange, weatherSummary{}, cs, nil)
 		expect(t, "text.txt", got)
 	})
 }
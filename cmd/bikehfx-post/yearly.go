@@ -23,7 +23,7 @@ func newYearlyCmd(rootConfig *rootConfig) *ffcli.Command {
 		year  = fs.String("year", time.Now().AddDate(-1, 0, 0).Format("2006"), "year to post for, in YYYY form")
 		years commaSeparatedString
 	)
-	fs.Var(&years, "years", "comma-separated years to post, in YYYY form, preferred over year")
+	fs.Var(&years, "years", "comma-separated years to post, each as its own thread, in YYYY form, preferred over year")
 
 	return &ffcli.Command{
 		Name:       "yearly",
@@ -36,39 +36,40 @@ func newYearlyCmd(rootConfig *rootConfig) *ffcli.Command {
 				years = []string{*year}
 			}
 
-			return yearlyExec(ctx, years, rootConfig.trq, rootConfig.rc, rootConfig.tp)
+			return yearlyExec(ctx, years, rootConfig.trq, rootConfig.wr, rootConfig.rc, rootConfig.tp, rootConfig.postedRecords())
 		},
 	}
 }
 
-func yearlyExec(ctx context.Context, years []string, trq counterbaseTimeRangeQuerier, rc recordser, tp threadPoster) error {
+func yearlyExec(ctx context.Context, years []string, trq counterbaseTimeRangeQuerier, wr weatherer, rc recordser, tp threadPoster, rj *recordJournal) error {
 	loc, err := time.LoadLocation("America/Halifax")
 	if err != nil {
 		return errutil.With(err)
 	}
 
-	var posts []post
+	var threads []periodThread
 	for _, year := range years {
 		yeart, err := time.ParseInLocation("2006", year, loc)
 		if err != nil {
 			return errutil.With(err)
 		}
 
-		ps, err := yearPost(ctx, yeart, trq, rc)
+		key := threadKey{kind: "yearly", period: yeart.Format("2006")}
+		threads, err = addPeriodThread(threads, key, func() ([]post, []recordJournalEntry, error) {
+			return yearPost(ctx, yeart, trq, wr, rc)
+		})
 		if err != nil {
 			return errutil.With(err)
 		}
-
-		posts = append(posts, ps...)
 	}
 
-	if _, err := tp.postThread(ctx, posts); err != nil {
+	if err := postPeriodThreads(ctx, tp, rj, threads); err != nil {
 		return errutil.With(err)
 	}
 	return nil
 }
 
-func yearPost(ctx context.Context, yeart time.Time, trq counterbaseTimeRangeQuerier, rc recordser) ([]post, error) {
+func yearPost(ctx context.Context, yeart time.Time, trq counterbaseTimeRangeQuerier, weatherer weatherer, rc recordser) ([]post, []recordJournalEntry, error) {
 	var posts []post
 
 	yearRange := newTimeRangeDate(time.Date(yeart.Year(), 1, 1, 0, 0, 0, 0, yeart.Location()), 1, 0, 0)
@@ -76,7 +77,7 @@ func yearPost(ctx context.Context, yeart time.Time, trq counterbaseTimeRangeQuer
 	yearDays := yearRange.splitDate(0, 0, 1)
 	yearDaySeries, err := trq.query(ctx, yearDays...)
 	if err != nil {
-		return nil, errutil.With(err)
+		return nil, nil, errutil.With(err)
 	}
 
 	yearRanges := []timeRange{yearRange}
@@ -90,7 +91,7 @@ func yearPost(ctx context.Context, yeart time.Time, trq counterbaseTimeRangeQuer
 	for _, wr := range yearRanges {
 		yearSeries, err := trq.query(ctx, wr)
 		if err != nil {
-			return nil, errutil.With(err)
+			return nil, nil, errutil.With(err)
 		}
 
 		yearsSeries = append(yearsSeries, yearSeries)
@@ -106,12 +107,17 @@ func yearPost(ctx context.Context, yeart time.Time, trq counterbaseTimeRangeQuer
 			series:  c.series,
 		})
 	}
-	records, err := rc.records(ctx, yearRange.begin, cs, recordWidthYear)
+	records, broken, err := rc.records(ctx, yearRange.begin, cs, recordWidthYear)
 	if err != nil {
-		return nil, errutil.With(err)
+		return nil, nil, errutil.With(err)
 	}
 
-	yearPostText := yearPostText(yearRange, yearsSeries[0], records)
+	rangesWeather := make([]weatherSummary, len(yearRanges))
+	for i, r := range yearRanges {
+		rangesWeather[i] = summarizeWeather(ctx, weatherer, r)
+	}
+
+	yearPostText := yearPostText(yearRange, rangesWeather[0], yearsSeries[0], records)
 
 	graphBegin := yearRange.begin.AddDate(-7, 0, 0)
 	graphRange := newTimeRangeDate(graphBegin, 8, 0, 0)
@@ -119,7 +125,7 @@ func yearPost(ctx context.Context, yeart time.Time, trq counterbaseTimeRangeQuer
 
 	graphCountSeries, err := trq.query(ctx, graphYears...)
 	if err != nil {
-		return nil, errutil.With(err)
+		return nil, nil, errutil.With(err)
 	}
 
 	yearCounts := make(map[time.Time]int)
@@ -136,7 +142,7 @@ func yearPost(ctx context.Context, yeart time.Time, trq counterbaseTimeRangeQuer
 
 	gr, err := timeRangeBarGraph(graphTRVs, "Total count by year", func(tr timeRange) string { return tr.begin.Format("2006") })
 	if err != nil {
-		return nil, errutil.With(err)
+		return nil, nil, errutil.With(err)
 	}
 
 	atg := altTextGenerator{
@@ -161,7 +167,7 @@ func yearPost(ctx context.Context, yeart time.Time, trq counterbaseTimeRangeQuer
 
 	altText, err := atg.text(graphTRVs)
 	if err != nil {
-		return nil, errutil.With(err)
+		return nil, nil, errutil.With(err)
 	}
 
 	posts = append(posts, post{
@@ -188,14 +194,18 @@ func yearPost(ctx context.Context, yeart time.Time, trq counterbaseTimeRangeQuer
 
 	prevYearsPostPrinter := message.NewPrinter(language.English)
 	prevYearsPostText := prevYearsPostPrinter.Sprintf("Previous year counts:\n\n")
-	for _, trv := range graph2TRVs {
-		prevYearsPostText += prevYearsPostPrinter.Sprintf("%v: %v\n", trv.tr.begin.Format("2006"), trv.val)
+	for i, trv := range graph2TRVs {
+		prevYearsPostText += prevYearsPostPrinter.Sprintf("%v: %v", trv.tr.begin.Format("2006"), trv.val)
+		if wt := rangesWeather[i].shortText(); wt != "" {
+			prevYearsPostText += " · " + wt
+		}
+		prevYearsPostText += "\n"
 	}
 
 	slices.Reverse(graph2TRVs)
 	gr2, err := timeRangeBarGraph(graph2TRVs, prevYearsPostPrinter.Sprintf("Total count by year"), func(tr timeRange) string { return tr.begin.Format("2006") })
 	if err != nil {
-		return nil, errutil.With(err)
+		return nil, nil, errutil.With(err)
 	}
 
 	atg2 := altTextGenerator{
@@ -220,7 +230,7 @@ func yearPost(ctx context.Context, yeart time.Time, trq counterbaseTimeRangeQuer
 
 	altText2, err := atg2.text(graph2TRVs)
 	if err != nil {
-		return nil, errutil.With(err)
+		return nil, nil, errutil.With(err)
 	}
 
 	posts = append(posts, post{
@@ -254,7 +264,7 @@ func yearPost(ctx context.Context, yeart time.Time, trq counterbaseTimeRangeQuer
 	pastThreeYearsWeeks := pastThreeYears.splitDate(0, 0, 7)
 	pastThreeYearsWeeksSeries, err := trq.query(ctx, pastThreeYearsWeeks...)
 	if err != nil {
-		return nil, errutil.With(err)
+		return nil, nil, errutil.With(err)
 	}
 
 	countersByID := make(map[string]directory.Counter)
@@ -323,7 +333,7 @@ func yearPost(ctx context.Context, yeart time.Time, trq counterbaseTimeRangeQuer
 		slices.Reverse(graph2TRVs)
 		gr2, err := timeRangeBarGraph(graph2TRVs, prevYearsPostPrinter.Sprintf("Total count by year for %v", counterName(c)), func(tr timeRange) string { return tr.begin.Format("2006") })
 		if err != nil {
-			return nil, errutil.With(err)
+			return nil, nil, errutil.With(err)
 		}
 
 		atg2 := altTextGenerator{
@@ -348,7 +358,7 @@ func yearPost(ctx context.Context, yeart time.Time, trq counterbaseTimeRangeQuer
 
 		altText2, err := atg2.text(graph2TRVs)
 		if err != nil {
-			return nil, errutil.With(err)
+			return nil, nil, errutil.With(err)
 		}
 
 		pastThreeYearsWeekCountsByYear := pastThreeYearsWeekCountsByCounterByYear[id]
@@ -372,12 +382,12 @@ func yearPost(ctx context.Context, yeart time.Time, trq counterbaseTimeRangeQuer
 		}
 		gr3, err := yearWeekChart(pastThreeYearsWeekCountsByYear, fmt.Sprintf("Total count by week for %v for recent years", counterName(c)))
 		if err != nil {
-			return nil, errutil.With(err)
+			return nil, nil, errutil.With(err)
 		}
 
 		heatmapImage, heatmapAlt, err := buildYearCounterHeatmap(ctx, c, yearRange, yearHeatmapAxis, dayCountsByCounter[id])
 		if err != nil {
-			return nil, errutil.With(err)
+			return nil, nil, errutil.With(err)
 		}
 
 		media := []postMedia{
@@ -394,7 +404,7 @@ func yearPost(ctx context.Context, yeart time.Time, trq counterbaseTimeRangeQuer
 		})
 	}
 
-	return posts, nil
+	return posts, broken, nil
 }
 
 type yearHeatmapAxis struct {
@@ -508,7 +518,7 @@ func buildYearCounterHeatmap(ctx context.Context, counter directory.Counter, yea
 	return imgBytes, alt, nil
 }
 
-func yearPostText(yearRange timeRange, cs []counterSeries, records map[string]recordKind) string {
+func yearPostText(yearRange timeRange, ws weatherSummary, cs []counterSeries, records map[string]recordKind) string {
 	var out strings.Builder
 
 	p := message.NewPrinter(language.English)
@@ -528,6 +538,9 @@ func yearPostText(yearRange timeRange, cs []counterSeries, records map[string]re
 	}
 
 	p.Fprintf(&out, "Year review:\n\n%v%v #BikeHfx bikes counted in %v\n\n", sum, recordSymbol(records["sum"]), yearRange.begin.Format("2006"))
+	if t := ws.text(); t != "" {
+		p.Fprintf(&out, "%v\n\n", t)
+	}
 
 	slices.SortFunc(presentIndices, func(i, j int) int {
 		return cmp.Compare(counterName(cs[i].counter), counterName(cs[j].counter))
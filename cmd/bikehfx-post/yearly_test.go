@@ -0,0 +1,22 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestYearlyPostText(t *testing.T) {
+	t.Parallel()
+
+	yearRange := newTimeRangeDate(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), 1, 0, 0)
+
+	var banana, apple counterSeries
+	banana.counter.ID, banana.counter.Name = "b", "Banana"
+	banana.series = []timeRangeValue{{tr: yearRange, val: 45678}}
+	apple.counter.ID, apple.counter.Name = "a", "Apple"
+	apple.series = []timeRangeValue{{tr: yearRange, val: 12345}}
+
+	ws := weatherSummary{days: 365, meanMax: 12.6, meanMin: 3.1, rain: 1402.5, snow: 128.2, wetDays: 141, freezingDays: 97}
+	got := yearPostText(yearRange, ws, []counterSeries{banana, apple}, map[string]recordKind{"a": recordKindAllTime})
+	expect(t, "text.txt", got)
+}
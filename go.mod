@@ -4,14 +4,17 @@ go 1.26
 
 require (
 	github.com/bluesky-social/indigo v0.0.0-20241223053147-c130614850e5
+	github.com/btcsuite/btcd/btcec/v2 v2.3.4
 	github.com/danp/counterbase v0.0.0-20240303171822-ec4a89e295ad
 	github.com/dimchansky/utfbom v1.1.1
 	github.com/google/go-cmp v0.6.0
+	github.com/gorilla/websocket v1.5.1
 	github.com/graxinc/errutil v0.0.0-20230615185726-b495a08a0537
 	github.com/hexops/gotextdiff v1.0.3
 	github.com/hexops/valast v1.4.4
 	github.com/mattn/go-mastodon v0.0.6
 	github.com/peterbourgon/ff/v3 v3.3.1
+	github.com/rivo/uniseg v0.4.7
 	golang.org/x/image v0.42.0
 	golang.org/x/sync v0.21.0
 	golang.org/x/text v0.38.0
@@ -21,8 +24,11 @@ require (
 require (
 	git.sr.ht/~sbinet/gg v0.5.0 // indirect
 	github.com/ajstarks/svgo v0.0.0-20211024235047-1546f124cd8b // indirect
+	github.com/btcsuite/btcd/chaincfg/chainhash v1.0.1 // indirect
 	github.com/campoy/embedmd v1.0.0 // indirect
 	github.com/carlmjohnson/versioninfo v0.22.5 // indirect
+	github.com/decred/dcrd/crypto/blake256 v1.0.1 // indirect
+	github.com/decred/dcrd/dcrec/secp256k1/v4 v4.2.0 // indirect
 	github.com/felixge/httpsnoop v1.0.4 // indirect
 	github.com/go-fonts/liberation v0.3.1 // indirect
 	github.com/go-latex/latex v0.0.0-20230307184459-12ec69307ad9 // indirect
@@ -32,7 +38,6 @@ require (
 	github.com/gogo/protobuf v1.3.2 // indirect
 	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0 // indirect
 	github.com/google/uuid v1.4.0 // indirect
-	github.com/gorilla/websocket v1.5.1 // indirect
 	github.com/hashicorp/go-cleanhttp v0.5.2 // indirect
 	github.com/hashicorp/go-retryablehttp v0.7.7 // indirect
 	github.com/hashicorp/golang-lru v1.0.2 // indirect